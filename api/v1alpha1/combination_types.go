@@ -15,18 +15,75 @@ package v1alpha1
 import (
 	metautils "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 const (
 	TypeInvalid    = "Invalid"
 	TypeFinished   = "Finished"
 	TypeInProgress = "InProgress"
+	TypeApplied    = "Applied"
+	TypePlanned    = "Planned"
+	TypeSuspended  = "Suspended"
 
 	ReasonProcessing          = "Processing"
 	ReasonTemplateNotFound    = "TemplateNotFound"
 	ReasonTemplateBodyInvalid = "TemplateBodyInvalid"
 	ReasonEvaluationsInvalid  = "EvaluationsInvalid"
+	ReasonArgumentsInvalid    = "ArgumentsInvalid"
+	ReasonArgumentsMismatch   = "ArgumentsMismatch"
+	ReasonArgumentsUnresolved = "ArgumentsUnresolved"
 	ReasonProcessed           = "Processed"
+	ReasonApplied             = "Applied"
+	ReasonApplyFailed         = "ApplyFailed"
+	ReasonOverflowFailed      = "OverflowFailed"
+	ReasonPlanned             = "Planned"
+	ReasonPlanFailed          = "PlanFailed"
+	ReasonSuspended           = "Suspended"
+)
+
+const (
+	// CombinationLabel is set on every resource applied by combo to the name of the combination that generated it
+	CombinationLabel = "combo.io/combination"
+
+	// NamespacedCombinationLabel is set instead of CombinationLabel on every resource applied on behalf of a
+	// NamespacedCombination, which is unique within the resource's namespace
+	NamespacedCombinationLabel = "combo.io/namespaced-combination"
+
+	// PlanAnnotation requests a one-off plan of the changes to the combination's resources when set to "true",
+	// regardless of the combination's mode and even while it's suspended, without changing its spec. The plan is
+	// computed once instead of applying the resources, after which the annotation is removed.
+	PlanAnnotation = "combo.io/plan"
+
+	// CleanupFinalizer ensures the resources generated by a combination are handled before it is deleted
+	CleanupFinalizer = "combo.io/cleanup"
+)
+
+const (
+	StrategyProduct  = "Product"
+	StrategyPairwise = "Pairwise"
+	StrategyNWise    = "NWise"
+)
+
+const (
+	DeletionPolicyDelete = "Delete"
+	DeletionPolicyOrphan = "Orphan"
+)
+
+const (
+	ModeApply = "Apply"
+	ModePlan  = "Plan"
+)
+
+const (
+	ActionCreate = "Create"
+	ActionUpdate = "Update"
+	ActionDelete = "Delete"
+)
+
+const (
+	ResultApplied = "Applied"
+	ResultFailed  = "Failed"
 )
 
 // CombinationSpec defines arguments that replace parameters within the given template
@@ -36,8 +93,66 @@ type CombinationSpec struct {
 	Template string `json:"template"`
 
 	// Arguments contains the list of values to use for each parameter in the combination.
+	// Evaluations are ordered by the arguments: the values of the first argument change the
+	// slowest and those of the last argument change the fastest.
 	// +kubebuilder:validation:MinItems:=1
 	Arguments []Argument `json:"arguments,omitempty"`
+
+	// Zip contains groups of argument keys whose values are iterated in lockstep instead of being
+	// combined with each other; i.e. the first value of each key in a group is used together, then
+	// the second, and so on. Each group is then combined with the other groups and arguments as usual.
+	// Every key in a group must have the same number of values.
+	// +optional
+	Zip [][]string `json:"zip,omitempty"`
+
+	// Strategy determines which combinations of arguments are evaluated. Product evaluates every
+	// combination, Pairwise evaluates enough combinations for every pair of values of any two
+	// arguments to appear together at least once, and NWise does the same for the values of any
+	// Strength arguments. Pairwise and NWise usually evaluate far fewer combinations than Product.
+	// +kubebuilder:validation:Enum=Product;Pairwise;NWise
+	// +kubebuilder:default=Product
+	// +optional
+	Strategy string `json:"strategy,omitempty"`
+
+	// Strength is the number of arguments whose values must all appear together when using the NWise strategy.
+	// When it's at least the number of arguments, every combination is evaluated, just like with Product.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=6
+	// +optional
+	Strength int `json:"strength,omitempty"`
+
+	// Exclude contains partial combinations of arguments that should not be evaluated.
+	// A combination is excluded if it matches every key and value of any entry.
+	// +optional
+	Exclude []map[string]string `json:"exclude,omitempty"`
+
+	// DeletionPolicy determines what happens to the resources generated by the combination once it is deleted.
+	// Delete removes them from the cluster while Orphan leaves them in place.
+	// +kubebuilder:validation:Enum=Delete;Orphan
+	// +kubebuilder:default=Delete
+	// +optional
+	DeletionPolicy string `json:"deletionPolicy,omitempty"`
+
+	// Mode determines what happens to the evaluations. Apply applies them to the cluster, while Plan only
+	// determines what applying them would create, update and delete using a server-side dry run, and records
+	// it in the status without changing anything in the cluster.
+	// +kubebuilder:validation:Enum=Apply;Plan
+	// +kubebuilder:default=Apply
+	// +optional
+	Mode string `json:"mode,omitempty"`
+
+	// Suspend freezes the resources generated by the combination while set: it's neither evaluated nor applied,
+	// even when its template or the sources of its arguments change, until it's resumed. Deleting a suspended
+	// combination still honors its deletion policy.
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// ServiceAccountName is the ServiceAccount within the namespace of a NamespacedCombination that its resources
+	// are applied, planned and pruned as, so the combination can only manage what the ServiceAccount may manage.
+	// It's ignored by cluster-scoped Combinations.
+	// +kubebuilder:default=default
+	// +optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
 }
 
 // Argument defines a key and values for it that will be replaced in a template
@@ -45,17 +160,259 @@ type Argument struct {
 	// Key defines what is going to be replaced in the template
 	Key string `json:"key"`
 
-	// Values defines the options to replace the defined key
+	// Values defines the options to replace the defined key. Exactly one of Values and ValuesFrom must be set.
 	// +kubebuilder:validation:MinItems:=1
-	Values []string `json:"values"`
+	// +optional
+	Values []string `json:"values,omitempty"`
+
+	// ValuesFrom sources the options to replace the defined key from another object in the cluster.
+	// The combination is reevaluated whenever the object changes.
+	// +optional
+	ValuesFrom *ValuesSource `json:"valuesFrom,omitempty"`
+}
+
+// ValuesSource selects the objects holding the values of an argument. Exactly one of its fields must be set.
+type ValuesSource struct {
+	// ConfigMapKeyRef selects a key of a ConfigMap.
+	// +optional
+	ConfigMapKeyRef *KeyReference `json:"configMapKeyRef,omitempty"`
+
+	// SecretKeyRef selects a key of a Secret.
+	// +optional
+	SecretKeyRef *KeyReference `json:"secretKeyRef,omitempty"`
+
+	// NamespaceSelector selects namespaces by their labels, whose names become the values. An empty
+	// selector selects every namespace.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// ObjectSelector selects objects of any kind by their labels, reading a value from a field of each.
+	// +optional
+	ObjectSelector *ObjectSelector `json:"objectSelector,omitempty"`
+}
+
+// ObjectSelector selects live objects of a kind, whose values at FieldPath become the values of an argument.
+// Values are deduplicated and sorted, and objects without the field are skipped. The combination is
+// reevaluated whenever an object of the kind changes, so new objects automatically get resources.
+type ObjectSelector struct {
+	// APIVersion of the objects, e.g. v1 or apps/v1.
+	// +kubebuilder:validation:MinLength:=1
+	APIVersion string `json:"apiVersion"`
+
+	// Kind of the objects, e.g. Namespace.
+	// +kubebuilder:validation:MinLength:=1
+	Kind string `json:"kind"`
+
+	// Namespace limits the selection to objects within it. Objects in every namespace are selected if unset.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Selector selects objects by their labels. Every object of the kind is selected if unset.
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+
+	// FieldPath is the dot separated path to the field of each object holding its value, e.g. metadata.labels.tenant.
+	// The field must be a string, number or boolean.
+	// +kubebuilder:default=metadata.name
+	// +optional
+	FieldPath string `json:"fieldPath,omitempty"`
+}
+
+// KeyReference selects a key of a ConfigMap or Secret. The value of the key is either a JSON list
+// of strings, e.g. ["foo", "bar"], or holds one value per line, ignoring blank lines.
+type KeyReference struct {
+	// Namespace of the object. It defaults to the namespace of a NamespacedCombination, which may not
+	// refer to other namespaces, and must be set otherwise.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Name of the object.
+	// +kubebuilder:validation:MinLength:=1
+	Name string `json:"name"`
+
+	// Key within the object's data holding the values.
+	// +kubebuilder:validation:MinLength:=1
+	Key string `json:"key"`
 }
 
 // CombinationStatus defines the observed state of Combination
 type CombinationStatus struct {
 	// Conditions represents the current condition of the Combination.
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
-	// Represents the evaluation to this combination once processed
+
+	// ObservedGeneration is the generation of the spec the status was last updated for.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// TotalCombinations is the number of combinations of arguments that were evaluated.
+	// +optional
+	TotalCombinations int `json:"totalCombinations,omitempty"`
+
+	// RenderedManifests is the number of distinct manifests the combinations evaluated to.
+	// +optional
+	RenderedManifests int `json:"renderedManifests,omitempty"`
+
+	// AppliedManifests is the number of rendered manifests that were applied to the cluster.
+	// +optional
+	AppliedManifests int `json:"appliedManifests,omitempty"`
+
+	// FailedManifests is the number of rendered manifests that failed to apply.
+	// +optional
+	FailedManifests int `json:"failedManifests,omitempty"`
+
+	// Represents the evaluation to this combination once processed. Evaluations too large to fit in the status
+	// are stored in ConfigMaps instead, referenced by EvaluationsOverflow, in which case this is empty. So are
+	// evaluations containing values sourced from Secrets, which are always stored in Secrets instead.
 	Evaluations []string `json:"evaluations,omitempty"`
+
+	// EvaluationsOverflow references the ConfigMaps holding the evaluations when they're too large for the status,
+	// or the Secrets holding them when they contain values sourced from Secrets.
+	// +optional
+	EvaluationsOverflow *EvaluationsOverflow `json:"evaluationsOverflow,omitempty"`
+
+	// EvaluationRecords describes what produced each evaluation, in the same order as the evaluations. It's empty
+	// once the evaluations are too large for the status, since the records grow along with them.
+	// +optional
+	EvaluationRecords []EvaluationRecord `json:"evaluationRecords,omitempty"`
+
+	// Resources contains the outcome of applying each evaluation to the cluster.
+	// It also serves as the inventory of resources generated by the combination, which
+	// is used to prune resources that are no longer part of its evaluations. Since pruning
+	// needs them, the names and namespaces of the resources are kept even when they're
+	// rendered from arguments sourced from Secrets.
+	Resources []ResourceStatus `json:"resources,omitempty"`
+
+	// Plan summarizes what applying the evaluations would change in the cluster, while the combination is planned
+	// instead of applied or after a one-off plan until the combination's generation changes, even once the planned
+	// generation is applied. The rest of the status keeps describing the resources that were last applied.
+	// +optional
+	Plan *Plan `json:"plan,omitempty"`
+}
+
+// Plan summarizes the changes applying the evaluations of a combination would make to the cluster
+type Plan struct {
+	// ObservedGeneration is the generation of the combination that was planned.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Creates is the number of resources that would be created.
+	Creates int `json:"creates"`
+
+	// Updates is the number of resources that would be updated.
+	Updates int `json:"updates"`
+
+	// Deletes is the number of resources that would be pruned.
+	Deletes int `json:"deletes"`
+
+	// Unchanged is the number of resources that are already up to date.
+	Unchanged int `json:"unchanged"`
+
+	// Changes lists every resource that would be created, updated or deleted.
+	// +optional
+	Changes []PlannedChange `json:"changes,omitempty"`
+
+	// Failures lists the evaluations that would fail to apply.
+	// +optional
+	Failures []ResourceStatus `json:"failures,omitempty"`
+}
+
+// PlannedChange describes the change applying an evaluation, or pruning a resource, would make to a single resource
+type PlannedChange struct {
+	// Action is either Create, Update or Delete.
+	// +kubebuilder:validation:Enum=Create;Update;Delete
+	Action string `json:"action"`
+
+	// Resource references the object that would change.
+	Resource ResourceReference `json:"resource"`
+
+	// Fields lists the dot separated paths of the fields an update would change, e.g. data.name.
+	// +optional
+	Fields []string `json:"fields,omitempty"`
+}
+
+// EvaluationRecord describes what produced a single evaluation of the combination and what it was applied as
+type EvaluationRecord struct {
+	// Arguments holds the value of each parameter the evaluation was produced with. When several combinations
+	// of arguments produce the same manifest, it's only evaluated once and these are the first of them. The values
+	// of arguments sourced from Secrets are replaced with <redacted>.
+	// +optional
+	Arguments map[string]string `json:"arguments,omitempty"`
+
+	// ManifestIndex is the index of the manifest within the body of the template that was evaluated, counting
+	// from 0. With the GoTemplate engine, it's the index within the manifests rendered with the arguments instead.
+	ManifestIndex int `json:"manifestIndex"`
+
+	// Hash is the SHA-256 hash of the evaluated manifest, prefixed with sha256:.
+	Hash string `json:"hash"`
+
+	// Resource references the object the evaluation was applied as, once it's applied. The values of arguments
+	// sourced from Secrets are replaced with <redacted> within its namespace and name.
+	// +optional
+	Resource *ResourceReference `json:"resource,omitempty"`
+}
+
+// ResourceReference identifies an object in the cluster
+type ResourceReference struct {
+	// APIVersion of the object.
+	APIVersion string `json:"apiVersion"`
+
+	// Kind of the object.
+	Kind string `json:"kind"`
+
+	// Namespace of the object, empty for cluster-scoped objects.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Name of the object.
+	Name string `json:"name"`
+}
+
+// EvaluationsOverflow references the ConfigMaps or Secrets holding the evaluations of a combination. The evaluations
+// are split into chunks in order, each stored in an immutable ConfigMap or Secret named after the digest of its content
+// and owned by the combination, under the keys evaluation-0, evaluation-1, and so on, numbered across every chunk.
+type EvaluationsOverflow struct {
+	// Namespace of the ConfigMaps or Secrets.
+	Namespace string `json:"namespace"`
+
+	// ConfigMaps lists the names of the ConfigMaps holding the evaluations, in order.
+	// +optional
+	ConfigMaps []string `json:"configMaps,omitempty"`
+
+	// Secrets lists the names of the Secrets holding the evaluations, in order, which are used instead of
+	// ConfigMaps when the evaluations contain values sourced from Secrets.
+	// +optional
+	Secrets []string `json:"secrets,omitempty"`
+
+	// Count is the number of evaluations.
+	Count int `json:"count"`
+
+	// Size is the total size of the evaluations in bytes.
+	Size int `json:"size"`
+
+	// Digest is the SHA-256 digest of the evaluations, which changes whenever any of them does.
+	Digest string `json:"digest"`
+}
+
+// ResourceStatus describes the outcome of applying a single evaluation to the cluster
+type ResourceStatus struct {
+	// APIVersion of the applied resource.
+	APIVersion string `json:"apiVersion,omitempty"`
+
+	// Kind of the applied resource.
+	Kind string `json:"kind,omitempty"`
+
+	// Namespace of the applied resource, empty for cluster-scoped resources.
+	Namespace string `json:"namespace,omitempty"`
+
+	// Name of the applied resource.
+	Name string `json:"name,omitempty"`
+
+	// Result is either Applied or Failed.
+	// +kubebuilder:validation:Enum=Applied;Failed
+	Result string `json:"result"`
+
+	// Message contains the reason the resource failed to apply, if any.
+	Message string `json:"message,omitempty"`
 }
 
 // +genclient
@@ -64,6 +421,10 @@ type CombinationStatus struct {
 // +kubebuilder:storageversion
 // +kubebuilder:resource:categories=combo,scope=Cluster
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Combinations",type="integer",JSONPath=".status.totalCombinations"
+// +kubebuilder:printcolumn:name="Rendered",type="integer",JSONPath=".status.renderedManifests"
+// +kubebuilder:printcolumn:name="Applied",type="integer",JSONPath=".status.appliedManifests"
+// +kubebuilder:printcolumn:name="Failed",type="integer",JSONPath=".status.failedManifests"
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // Combination is the Schema for a combination
@@ -84,11 +445,49 @@ type CombinationList struct {
 	Items           []Combination `json:"items"`
 }
 
+// CombinationObject is implemented by every kind of combination, which are all evaluated the same way
+// +kubebuilder:object:generate=false
+type CombinationObject interface {
+	metav1.Object
+	runtime.Object
+
+	GetSpec() *CombinationSpec
+	GetStatus() *CombinationStatus
+}
+
+// CombinationObjectList is implemented by the lists of every kind of combination
+// +kubebuilder:object:generate=false
+type CombinationObjectList interface {
+	metav1.ListInterface
+	runtime.Object
+
+	GetCombinations() []CombinationObject
+}
+
 // SetCondition sets the condition if it has not already been set
 func (c *Combination) SetStatusCondition(condition metav1.Condition) {
 	metautils.SetStatusCondition(&c.Status.Conditions, condition)
 }
 
+// GetSpec returns the combination's spec
+func (c *Combination) GetSpec() *CombinationSpec {
+	return &c.Spec
+}
+
+// GetStatus returns the combination's status
+func (c *Combination) GetStatus() *CombinationStatus {
+	return &c.Status
+}
+
+// GetCombinations returns the combinations within the list
+func (l *CombinationList) GetCombinations() []CombinationObject {
+	combinations := make([]CombinationObject, 0, len(l.Items))
+	for i := range l.Items {
+		combinations = append(combinations, &l.Items[i])
+	}
+	return combinations
+}
+
 func init() {
 	SchemeBuilder.Register(&Combination{}, &CombinationList{})
 }
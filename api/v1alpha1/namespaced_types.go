@@ -0,0 +1,107 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+package v1alpha1
+*/
+package v1alpha1
+
+import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+// +genclient
+// +kubebuilder:object:root=true
+// +kubebuilder:storageversion
+// +kubebuilder:resource:categories=combo,scope=Namespaced
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Valid",type="string",JSONPath=".status.conditions[?(@.type==\"Valid\")].status"
+// +kubebuilder:printcolumn:name="Manifests",type="integer",JSONPath=".status.manifests"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// NamespacedTemplate is a Template that lives within a namespace, where only NamespacedCombinations can evaluate it.
+type NamespacedTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   TemplateSpec   `json:"spec"`
+	Status TemplateStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NamespacedTemplateList contains a list of NamespacedTemplate
+type NamespacedTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NamespacedTemplate `json:"items"`
+}
+
+// +genclient
+// +kubebuilder:object:root=true
+// +kubebuilder:storageversion
+// +kubebuilder:resource:categories=combo,scope=Namespaced
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Combinations",type="integer",JSONPath=".status.totalCombinations"
+// +kubebuilder:printcolumn:name="Rendered",type="integer",JSONPath=".status.renderedManifests"
+// +kubebuilder:printcolumn:name="Applied",type="integer",JSONPath=".status.appliedManifests"
+// +kubebuilder:printcolumn:name="Failed",type="integer",JSONPath=".status.failedManifests"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// NamespacedCombination is a Combination that lives within a namespace, so it can be created without cluster-wide
+// permissions. It evaluates the NamespacedTemplate of the given name in its own namespace, and everything it
+// touches is confined to that namespace: its resources must be namespaced and are applied to it, defaulting
+// their namespace, and its arguments may only source values from objects within it.
+type NamespacedCombination struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CombinationSpec   `json:"spec"`
+	Status CombinationStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NamespacedCombinationList contains a list of NamespacedCombination
+type NamespacedCombinationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NamespacedCombination `json:"items"`
+}
+
+// GetSpec returns the template's spec
+func (t *NamespacedTemplate) GetSpec() *TemplateSpec {
+	return &t.Spec
+}
+
+// GetStatus returns the template's status
+func (t *NamespacedTemplate) GetStatus() *TemplateStatus {
+	return &t.Status
+}
+
+// GetSpec returns the combination's spec
+func (c *NamespacedCombination) GetSpec() *CombinationSpec {
+	return &c.Spec
+}
+
+// GetStatus returns the combination's status
+func (c *NamespacedCombination) GetStatus() *CombinationStatus {
+	return &c.Status
+}
+
+// GetCombinations returns the combinations within the list
+func (l *NamespacedCombinationList) GetCombinations() []CombinationObject {
+	combinations := make([]CombinationObject, 0, len(l.Items))
+	for i := range l.Items {
+		combinations = append(combinations, &l.Items[i])
+	}
+	return combinations
+}
+
+func init() {
+	SchemeBuilder.Register(&NamespacedTemplate{}, &NamespacedTemplateList{}, &NamespacedCombination{}, &NamespacedCombinationList{})
+}
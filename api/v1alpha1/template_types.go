@@ -12,16 +12,168 @@ package v1alpha1
 */
 package v1alpha1
 
-import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+import (
+	"bytes"
+	"encoding/json"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	SubstitutionText = "Text"
+	SubstitutionYAML = "YAML"
+)
+
+const (
+	EngineReplace    = "Replace"
+	EngineGoTemplate = "GoTemplate"
+)
+
+const (
+	TypeValid = "Valid"
+
+	ReasonValidated         = "Validated"
+	ReasonParametersInvalid = "ParametersInvalid"
+)
+
+const (
+	ParameterTypeString        = "string"
+	ParameterTypeInt           = "int"
+	ParameterTypeBool          = "bool"
+	ParameterTypeNamespaceName = "namespace-name"
+	ParameterTypeDNSLabel      = "dns-label"
+)
 
 // TemplateSpec defines the desired state of a Template
 type TemplateSpec struct {
 	// Body is the parameterized template string.
 	Body string `json:"body"`
 
-	// Parameters is the set of strings within Body to treat as parameters.
+	// Parameters is the set of strings within Body to treat as parameters, along with the
+	// rules the arguments of a combination must follow for each of them. A parameter may also
+	// be given as just its name, as in earlier releases, in which case it's required.
 	// +kubebuilder:validation:MinItems:=1
-	Parameters []string `json:"parameters,omitempty"`
+	Parameters []Parameter `json:"parameters,omitempty"`
+
+	// Engine determines how Body is evaluated with each combination of arguments. Replace replaces
+	// each parameter within Body with its value, while GoTemplate renders Body as a Go text/template
+	// with the combination as its data, e.g. {{ .NAME }}, so it can use conditionals, loops and a
+	// curated set of functions. GoTemplate may render any number of manifests per combination.
+	// +kubebuilder:validation:Enum=Replace;GoTemplate
+	// +kubebuilder:default=Replace
+	// +optional
+	Engine string `json:"engine,omitempty"`
+
+	// Substitution determines how parameters are replaced within Body. Text replaces parameters
+	// anywhere in the raw text of Body, while YAML parses each manifest and only replaces parameters
+	// within scalar keys and values, quoting the result as needed to keep the manifest valid.
+	// Only the Replace engine supports YAML.
+	// +kubebuilder:validation:Enum=Text;YAML
+	// +kubebuilder:default=Text
+	// +optional
+	Substitution string `json:"substitution,omitempty"`
+
+	// Delimiters enclose each parameter within Body, e.g. ${{ NAME }}. When set, only delimited
+	// parameters are replaced, so parameters can't collide with the rest of Body. Whitespace
+	// between the delimiters and the parameter is ignored. With the GoTemplate engine, they
+	// replace the default {{ and }} action delimiters instead.
+	// +optional
+	Delimiters *Delimiters `json:"delimiters,omitempty"`
+}
+
+// Parameter describes a parameter within the body of a template and the values it accepts.
+// Its schema also accepts a plain string, which is the name of a required parameter.
+// +kubebuilder:validation:Type=""
+// +kubebuilder:validation:XPreserveUnknownFields
+type Parameter struct {
+	// Name is the string within Body to treat as the parameter. It must be an identifier, made of letters,
+	// digits and underscores and not starting with a digit.
+	// +kubebuilder:validation:MinLength:=1
+	// +kubebuilder:validation:Pattern=`^[A-Za-z_][A-Za-z0-9_]*$`
+	Name string `json:"name"`
+
+	// Description documents the purpose of the parameter.
+	// +optional
+	Description string `json:"description,omitempty"`
+
+	// Type is the type every value of the parameter must have. A namespace-name must be a valid
+	// namespace name and a dns-label a valid DNS-1123 label.
+	// +kubebuilder:validation:Enum=string;int;bool;namespace-name;dns-label
+	// +kubebuilder:default=string
+	// +optional
+	Type string `json:"type,omitempty"`
+
+	// Default is the value of the parameter when a combination has no arguments for it.
+	// +optional
+	Default *string `json:"default,omitempty"`
+
+	// Enum is the set of values the parameter accepts, if set.
+	// +optional
+	Enum []string `json:"enum,omitempty"`
+
+	// Pattern is a regular expression every value of the parameter must match in full, if set.
+	// +optional
+	Pattern string `json:"pattern,omitempty"`
+
+	// Required determines whether combinations must have arguments for the parameter.
+	// A parameter with a default never needs arguments.
+	// +optional
+	Required bool `json:"required,omitempty"`
+}
+
+// UnmarshalJSON decodes a parameter from either its object form or the plain string naming a required parameter,
+// which is how parameters were declared before they had any rules. Other scalars are treated as names as well,
+// leaving it to the validation of the parameters to reject them.
+func (p *Parameter) UnmarshalJSON(data []byte) error {
+	var name string
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		type parameter Parameter
+		return json.Unmarshal(data, (*parameter)(p))
+	case bytes.Equal(trimmed, []byte("null")):
+		*p = Parameter{}
+		return nil
+	case bytes.HasPrefix(trimmed, []byte(`"`)):
+		if err := json.Unmarshal(data, &name); err != nil {
+			return err
+		}
+	default:
+		name = string(trimmed)
+	}
+	*p = Parameter{Name: name, Required: true}
+	return nil
+}
+
+// Delimiters mark the start and end of a parameter within a template
+type Delimiters struct {
+	// Left marks the start of a parameter, e.g. ${{.
+	// +kubebuilder:validation:MinLength:=1
+	Left string `json:"left"`
+
+	// Right marks the end of a parameter, e.g. }}.
+	// +kubebuilder:validation:MinLength:=1
+	Right string `json:"right"`
+}
+
+// TemplateStatus describes the observed state of a Template
+type TemplateStatus struct {
+	// Conditions represents the current condition of the Template.
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// DeclaredParameters lists the names of the parameters within the spec.
+	DeclaredParameters []string `json:"declaredParameters,omitempty"`
+
+	// DiscoveredParameters lists the parameters found within Body. With delimiters or the
+	// GoTemplate engine every referenced parameter is found, otherwise only declared ones are.
+	DiscoveredParameters []string `json:"discoveredParameters,omitempty"`
+
+	// Manifests is the number of manifests within Body.
+	Manifests int `json:"manifests,omitempty"`
+
+	// Combinations lists the names of the combinations that evaluate the template.
+	Combinations []string `json:"combinations,omitempty"`
 }
 
 // +genclient
@@ -29,13 +181,18 @@ type TemplateSpec struct {
 // +kubebuilder:object:root=true
 // +kubebuilder:storageversion
 // +kubebuilder:resource:categories=combo,scope=Cluster
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Valid",type="string",JSONPath=".status.conditions[?(@.type==\"Valid\")].status"
+// +kubebuilder:printcolumn:name="Manifests",type="integer",JSONPath=".status.manifests"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // Template is a custom resource that represents a parameterized set of Kubernetes manifests.
 type Template struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
-	Spec TemplateSpec `json:"spec"`
+	Spec   TemplateSpec   `json:"spec"`
+	Status TemplateStatus `json:"status,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -47,6 +204,26 @@ type TemplateList struct {
 	Items           []Template `json:"items"`
 }
 
+// TemplateObject is implemented by every kind of template, which are all evaluated the same way
+// +kubebuilder:object:generate=false
+type TemplateObject interface {
+	metav1.Object
+	runtime.Object
+
+	GetSpec() *TemplateSpec
+	GetStatus() *TemplateStatus
+}
+
+// GetSpec returns the template's spec
+func (t *Template) GetSpec() *TemplateSpec {
+	return &t.Spec
+}
+
+// GetStatus returns the template's status
+func (t *Template) GetStatus() *TemplateStatus {
+	return &t.Status
+}
+
 func init() {
 	SchemeBuilder.Register(&Template{}, &TemplateList{})
 }
@@ -4,22 +4,42 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"strconv"
 	"strings"
 
+	"github.com/operator-framework/combo/api/v1alpha1"
 	"github.com/operator-framework/combo/pkg/combination"
+	"github.com/operator-framework/combo/pkg/parameter"
 	"github.com/operator-framework/combo/pkg/template"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
 )
 
 var (
-	ErrEmptyFile      = errors.New("empty file")
-	FilePathArgsIndex = 0
+	ErrEmptyFile         = errors.New("empty file")
+	ErrInvalidExclusion  = errors.New("invalid exclusion")
+	ErrInvalidShard      = errors.New("invalid shard")
+	ErrInvalidDelimiters = errors.New("invalid delimiters")
+	ErrInvalidTemplate   = errors.New("invalid template")
+	FilePathArgsIndex    = 0
 )
 
 func init() {
 	evalCmd.Flags().StringToStringP("replacements", "r", map[string]string{}, "Key value pair of comma delimited values. Example: 'NAMESPACE=foo,bar'")
+	evalCmd.Flags().StringArrayP("exclude", "x", []string{}, "Comma delimited key value pairs of a partial combination to exclude. May be specified multiple times. Example: 'TARGET_GROUP=sre,TARGET_NAMESPACE=prod'")
+	evalCmd.Flags().StringArrayP("zip", "z", []string{}, "Comma delimited keys whose values are iterated in lockstep instead of combined with each other. May be specified multiple times. Example: 'NAMESPACE,QUOTA'")
+	evalCmd.Flags().String("strategy", "product", "Determines which combinations are evaluated: product evaluates all of them, pairwise only enough to use every pair of values of any two keys together, and nwise every set of values of any --strength keys.")
+	evalCmd.Flags().Int("strength", 0, "The number of keys whose values must all be used together with the nwise strategy, at most 6.")
+	evalCmd.Flags().String("shard", "", "Only evaluate one of several equal, disjoint slices of the combinations, in the form INDEX/TOTAL where 0 <= INDEX < TOTAL. Example: '3/10'")
+	evalCmd.Flags().String("engine", "replace", "Determines how the file is evaluated: replace replaces each key with its value, while gotemplate renders the file as a Go text/template with the combination as its data, e.g. '{{ .NAMESPACE }}'.")
+	evalCmd.Flags().String("substitution", "text", "Determines how replacements are made: text replaces keys anywhere in the file, while yaml only replaces whole words within YAML keys and values and quotes the results as needed.")
+	evalCmd.Flags().String("delimiters", "", "Only replace keys enclosed by the given left and right delimiters, separated by a space. Example: '${{ }}'")
+	evalCmd.Flags().BoolP("template", "t", false, "Treat the file as a combo.io Template resource: its body is evaluated as its engine, substitution and delimiters describe, once the replacements are checked against its parameters and their defaults are filled in.")
+	evalCmd.Flags().Bool("count", false, "Print the number of combinations of the replacements, including excluded combinations, instead of evaluating them.")
 	evalCmd.Flags().Bool("presolve", false, "Toggles how combinations are generated. When applied combinations are generated all at once.")
 
 	if err := evalCmd.MarkFlagRequired("replacements"); err != nil {
@@ -38,6 +58,145 @@ func formatReplacements(replacements map[string]string) map[string][]string {
 	return formattedReplacements
 }
 
+// formatZip takes the zipped key groups from the args and formats them
+// in a way that the combinations package wants
+func formatZip(zip []string) [][]string {
+	formattedZip := [][]string{}
+	for _, group := range zip {
+		formattedZip = append(formattedZip, strings.Split(group, ","))
+	}
+	return formattedZip
+}
+
+// parseShard takes the shard from the args in the form INDEX/TOTAL and
+// returns its index and the total number of shards
+func parseShard(shard string) (int, int, error) {
+	indexTotal := strings.SplitN(shard, "/", 2)
+	if len(indexTotal) != 2 {
+		return 0, 0, fmt.Errorf("%w: %q is not in the form INDEX/TOTAL", ErrInvalidShard, shard)
+	}
+
+	index, err := strconv.Atoi(indexTotal[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: index %q is not a number", ErrInvalidShard, indexTotal[0])
+	}
+
+	total, err := strconv.Atoi(indexTotal[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: total %q is not a number", ErrInvalidShard, indexTotal[1])
+	}
+
+	if total < 1 || index < 0 || index >= total {
+		return 0, 0, fmt.Errorf("%w: %q must satisfy 0 <= INDEX < TOTAL", ErrInvalidShard, shard)
+	}
+
+	return index, total, nil
+}
+
+// parseDelimiters splits delimiters in the form "LEFT RIGHT" into the left and right delimiter
+func parseDelimiters(delimiters string) (string, string, error) {
+	leftRight := strings.Fields(delimiters)
+	if len(leftRight) != 2 {
+		return "", "", fmt.Errorf("%w: %q is not in the form 'LEFT RIGHT'", ErrInvalidDelimiters, delimiters)
+	}
+	return leftRight[0], leftRight[1], nil
+}
+
+// openInput opens the file at the given path, unless input is being piped to STDIN
+func openInput(path string) (io.Reader, error) {
+	// Determine if input is from pipe or designated input file
+	fi, err := os.Stdin.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("error accessing STDIN: %w", err)
+	}
+
+	if fi.Mode()&os.ModeNamedPipe != 0 {
+		return os.Stdin, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file specified: %w", err)
+	}
+	return file, nil
+}
+
+// decodeTemplate decodes a combo.io Template resource
+func decodeTemplate(input io.Reader) (*v1alpha1.Template, error) {
+	data, err := ioutil.ReadAll(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template: %w", err)
+	}
+
+	var resource v1alpha1.Template
+	if err := yaml.UnmarshalStrict(data, &resource); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidTemplate, err.Error())
+	}
+
+	expected := v1alpha1.GroupVersion.WithKind("Template")
+	if resource.GroupVersionKind() != expected {
+		return nil, fmt.Errorf("%w: expected a %s resource, got apiVersion %q and kind %q", ErrInvalidTemplate, expected.Kind, resource.APIVersion, resource.Kind)
+	}
+	return &resource, nil
+}
+
+// flagBuilderOptions returns the builder options described by the engine, substitution and delimiters flags
+func flagBuilderOptions(cmd *cobra.Command) ([]template.BuilderOption, error) {
+	substitutionName, err := cmd.Flags().GetString("substitution")
+	if err != nil {
+		return nil, fmt.Errorf("failed to access substitution flag: %w", err)
+	}
+
+	substitution, err := template.ParseSubstitution(substitutionName)
+	if err != nil {
+		return nil, err
+	}
+
+	engineName, err := cmd.Flags().GetString("engine")
+	if err != nil {
+		return nil, fmt.Errorf("failed to access engine flag: %w", err)
+	}
+
+	engine, err := template.ParseEngine(engineName)
+	if err != nil {
+		return nil, err
+	}
+
+	builderOptions := []template.BuilderOption{template.WithEngine(engine), template.WithSubstitution(substitution)}
+
+	delimiters, err := cmd.Flags().GetString("delimiters")
+	if err != nil {
+		return nil, fmt.Errorf("failed to access delimiters flag: %w", err)
+	}
+	if delimiters != "" {
+		left, right, err := parseDelimiters(delimiters)
+		if err != nil {
+			return nil, err
+		}
+		builderOptions = append(builderOptions, template.WithDelimiters(left, right))
+	}
+
+	return builderOptions, nil
+}
+
+// formatExclusions takes the exclusions from the args and formats them
+// in a way that the combinations package wants
+func formatExclusions(exclusions []string) ([]map[string]string, error) {
+	formattedExclusions := []map[string]string{}
+	for _, exclusion := range exclusions {
+		formattedExclusion := map[string]string{}
+		for _, pair := range strings.Split(exclusion, ",") {
+			keyVal := strings.SplitN(pair, "=", 2)
+			if len(keyVal) != 2 || keyVal[0] == "" {
+				return nil, fmt.Errorf("%w: %q is not in the form KEY=VALUE", ErrInvalidExclusion, pair)
+			}
+			formattedExclusion[keyVal[0]] = keyVal[1]
+		}
+		formattedExclusions = append(formattedExclusions, formattedExclusion)
+	}
+	return formattedExclusions, nil
+}
+
 var (
 	evalCmd = &cobra.Command{
 		Use:   "eval [file]",
@@ -48,7 +207,41 @@ Note: the combo binary requires the --replacement flag to be explicitly set.
 
 The replacements flag allows users to specify a series of key value pairs in the form of KEY=VALUES.
 
+The exclude flag allows users to skip any combination containing the given comma delimited KEY=VALUE pairs.
+
+The zip flag allows users to iterate the values of the given comma delimited keys in lockstep, so that the
+first value of each key is used together, then the second, and so on.
+
+The strategy flag allows users to evaluate a small subset of the combinations that still uses every pair of values
+(pairwise), or every set of values of --strength keys (nwise), together at least once.
+
+The shard flag allows users to split the combinations into TOTAL disjoint slices and only evaluate the one at INDEX,
+so that evaluating every INDEX from 0 to TOTAL-1 covers every combination exactly once.
+
+The engine flag allows users to render the file as a Go text/template instead, with each combination as its data,
+so the file can use conditionals, loops and functions such as default, upper or split.
+
+The substitution flag allows users to only replace keys that are whole words within the keys and values of each
+YAML manifest, leaving comments untouched and quoting any value that would otherwise break the manifest.
+
+The delimiters flag allows users to only replace keys enclosed by the given left and right delimiters, e.g.
+${{ NAMESPACE }}, so that keys can't collide with the rest of the file.
+
+The template flag allows users to evaluate a combo.io Template resource offline, exactly as the controller would.
+The replacement keys must match the template's parameters, whose defaults are filled in for any missing keys, and
+every value is checked against the rules of its parameter. The template's body is then evaluated with its own
+engine, substitution and delimiters.
+
+The count flag prints the number of combinations without evaluating any of them.
+
 Example: combo eval -r REPLACE_ME=1,2,3 path/to/file
+Example: combo eval -r REPLACE_ME=1,2,3 -r OTHER=a,b -x REPLACE_ME=1,OTHER=b path/to/file
+Example: combo eval -r NAMESPACE=foo,bar -r QUOTA=1Gi,2Gi -z NAMESPACE,QUOTA path/to/file
+Example: combo eval -r OS=linux,darwin -r ARCH=amd64,arm64 -r GO=1.20,1.21 --strategy pairwise path/to/file
+Example: combo eval -r NAME=foo,bar --substitution yaml path/to/file
+Example: combo eval -r NAME=foo,bar --delimiters '${{ }}' path/to/file
+Example: combo eval -r NAME=foo,bar --engine gotemplate path/to/file
+Example: combo eval -r TARGET_NAMESPACE=staging,prod --template path/to/template.yaml
 	`,
 		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -57,33 +250,127 @@ Example: combo eval -r REPLACE_ME=1,2,3 path/to/file
 				return fmt.Errorf("failed to access replacements flag: %w", err)
 			}
 
+			rawExclusions, err := cmd.Flags().GetStringArray("exclude")
+			if err != nil {
+				return fmt.Errorf("failed to access exclude flag: %w", err)
+			}
+
+			exclusions, err := formatExclusions(rawExclusions)
+			if err != nil {
+				return err
+			}
+
+			zip, err := cmd.Flags().GetStringArray("zip")
+			if err != nil {
+				return fmt.Errorf("failed to access zip flag: %w", err)
+			}
+
 			useSolvedAhead, err := cmd.Flags().GetBool("presolve")
 			if err != nil {
 				return err
 			}
 
-			// Determine if input is from pipe or designated input file
-			fi, err := os.Stdin.Stat()
+			count, err := cmd.Flags().GetBool("count")
+			if err != nil {
+				return err
+			}
+
+			strategyName, err := cmd.Flags().GetString("strategy")
+			if err != nil {
+				return fmt.Errorf("failed to access strategy flag: %w", err)
+			}
+
+			strength, err := cmd.Flags().GetInt("strength")
+			if err != nil {
+				return fmt.Errorf("failed to access strength flag: %w", err)
+			}
+
+			strategy, err := combination.ParseStrategy(strategyName, strength)
+			if err != nil {
+				return err
+			}
+
+			fromTemplate, err := cmd.Flags().GetBool("template")
 			if err != nil {
-				return fmt.Errorf("error accessing STDIN: %w", err)
+				return fmt.Errorf("failed to access template flag: %w", err)
 			}
 
-			var templateData *os.File
-			if fi.Mode()&os.ModeNamedPipe == 0 {
-				templateData, err = os.Open(args[FilePathArgsIndex])
+			// A Template resource has its parameters checked and brings its own body and builder options
+			streamArgs := formatReplacements(replacements)
+			var templateResource *v1alpha1.Template
+			if fromTemplate {
+				for _, flag := range []string{"engine", "substitution", "delimiters"} {
+					if cmd.Flags().Changed(flag) {
+						return fmt.Errorf("the %s flag can't be combined with the template flag, the template's spec is used instead", flag)
+					}
+				}
+
+				input, err := openInput(args[FilePathArgsIndex])
 				if err != nil {
-					return fmt.Errorf("failed to read file specified: %w", err)
+					return err
+				}
+
+				if templateResource, err = decodeTemplate(input); err != nil {
+					return err
+				}
+
+				if err := parameter.Match(templateResource.Spec.Parameters, streamArgs); err != nil {
+					return err
+				}
+
+				if streamArgs, err = parameter.Resolve(templateResource.Spec.Parameters, streamArgs); err != nil {
+					return err
 				}
-			} else {
-				templateData = os.Stdin
 			}
 
-			combinations := combination.NewStream(
-				combination.WithArgs(formatReplacements(replacements)),
+			streamOptions := []combination.StreamOption{
+				combination.WithArgs(streamArgs),
+				combination.WithZip(formatZip(zip)...),
+				combination.WithExclusions(exclusions),
+				combination.WithStrategy(strategy),
 				combination.WithSolveAhead(useSolvedAhead),
-			)
+			}
+
+			shard, err := cmd.Flags().GetString("shard")
+			if err != nil {
+				return fmt.Errorf("failed to access shard flag: %w", err)
+			}
+			if shard != "" {
+				index, total, err := parseShard(shard)
+				if err != nil {
+					return err
+				}
+				streamOptions = append(streamOptions, combination.WithShard(index, total))
+			}
+
+			combinations := combination.NewStream(streamOptions...)
+
+			if count {
+				// Seeking to the start surfaces any error found in the args without solving any combination
+				if err := combinations.Seek(0); err != nil {
+					return fmt.Errorf("failed to count combinations: %w", err)
+				}
+				fmt.Println(combinations.Len())
+				return nil
+			}
+
+			var templateData io.Reader
+			var builderOptions []template.BuilderOption
+			if templateResource != nil {
+				templateData = strings.NewReader(templateResource.Spec.Body)
+				if builderOptions, err = template.OptionsFor(templateResource.Spec); err != nil {
+					return err
+				}
+			} else {
+				if builderOptions, err = flagBuilderOptions(cmd); err != nil {
+					return err
+				}
+				if templateData, err = openInput(args[FilePathArgsIndex]); err != nil {
+					return err
+				}
+			}
 
-			templateBuilder, err := template.NewBuilder(templateData, combinations)
+			templateBuilder, err := template.NewBuilder(templateData, combinations, builderOptions...)
 			if err != nil {
 				return fmt.Errorf("failed to construct builder: %w", err)
 			}
@@ -1,8 +1,10 @@
 package cmd
 
 import (
+	"strings"
 	"testing"
 
+	"github.com/operator-framework/combo/api/v1alpha1"
 	"github.com/stretchr/testify/require"
 )
 
@@ -33,3 +35,237 @@ func TestFormatReplacements(t *testing.T) {
 		})
 	}
 }
+
+func TestFormatExclusions(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		input []string
+		want  []map[string]string
+		err   error
+	}{
+		{
+			name:  "formats exclusions correctly",
+			input: []string{"TARGET_GROUP=sre,TARGET_NAMESPACE=prod", "TARGET_GROUP=ci"},
+			want: []map[string]string{
+				{"TARGET_GROUP": "sre", "TARGET_NAMESPACE": "prod"},
+				{"TARGET_GROUP": "ci"},
+			},
+		},
+		{
+			name:  "handles empty values",
+			input: []string{"TEST="},
+			want:  []map[string]string{{"TEST": ""}},
+		},
+		{
+			name:  "handles empty input",
+			input: []string{},
+			want:  []map[string]string{},
+		},
+		{
+			name:  "rejects pairs without a value",
+			input: []string{"TEST=foo,bar"},
+			err:   ErrInvalidExclusion,
+		},
+		{
+			name:  "rejects pairs without a key",
+			input: []string{"=foo"},
+			err:   ErrInvalidExclusion,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := formatExclusions(tt.input)
+			require.ErrorIs(t, err, tt.err)
+			if tt.err == nil {
+				require.Equal(t, tt.want, got, "exclusions formatted incorrectly")
+			}
+		})
+	}
+}
+
+func TestFormatZip(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		input []string
+		want  [][]string
+	}{
+		{
+			name:  "formats zip groups correctly",
+			input: []string{"NAMESPACE,QUOTA", "GROUP,ROLE,BINDING"},
+			want:  [][]string{{"NAMESPACE", "QUOTA"}, {"GROUP", "ROLE", "BINDING"}},
+		},
+		{
+			name:  "handles empty input",
+			input: []string{},
+			want:  [][]string{},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, formatZip(tt.input), "zip groups formatted incorrectly")
+		})
+	}
+}
+
+func TestParseShard(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		input string
+		index int
+		total int
+		err   error
+	}{
+		{
+			name:  "parses a shard correctly",
+			input: "3/10",
+			index: 3,
+			total: 10,
+		},
+		{
+			name:  "parses the first shard",
+			input: "0/1",
+			index: 0,
+			total: 1,
+		},
+		{
+			name:  "rejects a shard without a total",
+			input: "3",
+			err:   ErrInvalidShard,
+		},
+		{
+			name:  "rejects a non-numeric shard",
+			input: "a/b",
+			err:   ErrInvalidShard,
+		},
+		{
+			name:  "rejects an index beyond the total",
+			input: "10/10",
+			err:   ErrInvalidShard,
+		},
+		{
+			name:  "rejects a total of zero",
+			input: "0/0",
+			err:   ErrInvalidShard,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			index, total, err := parseShard(tt.input)
+			require.ErrorIs(t, err, tt.err)
+			require.Equal(t, tt.index, index, "index parsed incorrectly")
+			require.Equal(t, tt.total, total, "total parsed incorrectly")
+		})
+	}
+}
+
+func TestParseDelimiters(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		input string
+		left  string
+		right string
+		err   error
+	}{
+		{
+			name:  "parses delimiters",
+			input: "${{ }}",
+			left:  "${{",
+			right: "}}",
+		},
+		{
+			name:  "ignores surrounding whitespace",
+			input: "  << >> ",
+			left:  "<<",
+			right: ">>",
+		},
+		{
+			name:  "rejects a single delimiter",
+			input: "{{}}",
+			err:   ErrInvalidDelimiters,
+		},
+		{
+			name:  "rejects more than two delimiters",
+			input: "{{ }} }}",
+			err:   ErrInvalidDelimiters,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			left, right, err := parseDelimiters(tt.input)
+			require.ErrorIs(t, err, tt.err)
+			require.Equal(t, tt.left, left, "left delimiter parsed incorrectly")
+			require.Equal(t, tt.right, right, "right delimiter parsed incorrectly")
+		})
+	}
+}
+
+func TestDecodeTemplate(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		input    string
+		expected v1alpha1.TemplateSpec
+		err      error
+	}{
+		{
+			name: "decodes a template",
+			input: `apiVersion: combo.io/v1alpha1
+kind: Template
+metadata:
+  name: feature
+spec:
+  body: "namespace: TARGET_NAMESPACE"
+  parameters:
+  - name: TARGET_NAMESPACE
+    type: namespace-name
+    required: true`,
+			expected: v1alpha1.TemplateSpec{
+				Body: "namespace: TARGET_NAMESPACE",
+				Parameters: []v1alpha1.Parameter{
+					{Name: "TARGET_NAMESPACE", Type: v1alpha1.ParameterTypeNamespaceName, Required: true},
+				},
+			},
+		},
+		{
+			name: "decodes parameters given as plain names as required",
+			input: `apiVersion: combo.io/v1alpha1
+kind: Template
+metadata:
+  name: feature
+spec:
+  body: "namespace: TARGET_NAMESPACE"
+  parameters:
+  - TARGET_NAMESPACE
+  - name: TARGET_GROUP
+    type: dns-label`,
+			expected: v1alpha1.TemplateSpec{
+				Body: "namespace: TARGET_NAMESPACE",
+				Parameters: []v1alpha1.Parameter{
+					{Name: "TARGET_NAMESPACE", Required: true},
+					{Name: "TARGET_GROUP", Type: v1alpha1.ParameterTypeDNSLabel},
+				},
+			},
+		},
+		{
+			name: "rejects a resource of another kind",
+			input: `apiVersion: combo.io/v1alpha1
+kind: Combination
+metadata:
+  name: feature`,
+			err: ErrInvalidTemplate,
+		},
+		{
+			name: "rejects unknown fields",
+			input: `apiVersion: combo.io/v1alpha1
+kind: Template
+spec:
+  bodies: []`,
+			err: ErrInvalidTemplate,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			resource, err := decodeTemplate(strings.NewReader(tt.input))
+			require.ErrorIs(t, err, tt.err)
+			if tt.err != nil {
+				return
+			}
+
+			require.Equal(t, tt.expected, resource.Spec)
+		})
+	}
+}
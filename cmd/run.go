@@ -1,16 +1,28 @@
 package cmd
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/operator-framework/combo/pkg/controller"
 	"github.com/operator-framework/combo/pkg/version"
+	"github.com/operator-framework/combo/pkg/webhook"
 	"github.com/spf13/cobra"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/manager/signals"
 )
 
 func init() {
 	runCmd.Flags().Int("verbosity", 1, "Sets verbosity level of combo CR controller with default verbosity 1. Verbosity decreases as the value given increases.")
+	runCmd.Flags().String("webhook-cert-dir", "", "Serve the validating admission webhook with the tls.crt and tls.key certificates in this directory. The webhook is not served if unset.")
+	runCmd.Flags().Int("webhook-port", 9443, "The port the validating admission webhook is served on.")
+	runCmd.Flags().Int("max-product-size", 0, "Reject combinations whose arguments have more combinations than this, regardless of their strategy. There's no limit if 0. Only enforced by the webhook.")
+	runCmd.Flags().Int("max-status-evaluations-size", 256*1024, "Store the evaluations of a combination in ConfigMaps instead of its status once they're larger than this many bytes. They're always stored in the status if 0.")
+	runCmd.Flags().String("evaluations-namespace", "combo", "The namespace to store the evaluations of cluster-scoped combinations in, once they're too large for their status or hold values sourced from Secrets.")
+	runCmd.Flags().StringSlice("selectable-kinds", []string{"Namespace", "ConfigMap", "ServiceAccount"}, "The kinds of objects arguments may select their values from, as Kind for core kinds or Kind.group otherwise, e.g. Deployment.apps. Selected kinds are watched across the cluster.")
+	runCmd.Flags().String("service-account", "", "The namespace/name of the ServiceAccount to manage the resources of cluster-scoped combinations as. They're managed as the controller itself if unset.")
 }
 
 var runCmd = &cobra.Command{
@@ -19,12 +31,48 @@ var runCmd = &cobra.Command{
 	Long: `Run Combo as a controller on the cluster to begin reconciling new events.
 
 This will reconcile any events for the Combination and Template resources.
+
+The webhook-cert-dir flag allows users to also serve a validating admission webhook with the certificates in the
+given directory. It rejects Templates that are invalid or reference undeclared parameters at
+/validate-combo-io-v1alpha1-template, and Combinations whose arguments don't match the parameters of their template
+or have more combinations than the max-product-size flag allows at /validate-combo-io-v1alpha1-combination.
+
+The max-status-evaluations-size flag limits the size of the evaluations recorded in the status of a combination.
+Larger evaluations are stored in chunks within immutable ConfigMaps owned by the combination instead, in the
+namespace of a NamespacedCombination or the namespace given by the evaluations-namespace flag for a Combination.
+Evaluations holding values sourced from Secrets are always stored that way, in immutable Secrets instead.
+
+The selectable-kinds flag restricts the kinds of objects the arguments of a combination may select their values
+from, since combo watches every object of a selected kind. Combinations selecting other kinds aren't evaluated.
+
+The service-account flag makes combo impersonate the given ServiceAccount to manage the resources of Combinations,
+so that combo itself doesn't need to be allowed to manage any resource. The resources of a NamespacedCombination are
+always managed as the ServiceAccount named by its serviceAccountName, within its namespace.
+
+Example: combo run --webhook-cert-dir path/to/certs --max-product-size 1000
 `,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ctrl.SetLogger(rootLog)
 
+		certDir, err := cmd.Flags().GetString("webhook-cert-dir")
+		if err != nil {
+			return err
+		}
+
+		webhookPort, err := cmd.Flags().GetInt("webhook-port")
+		if err != nil {
+			return err
+		}
+
+		maxProductSize, err := cmd.Flags().GetInt("max-product-size")
+		if err != nil {
+			return err
+		}
+
 		mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-			Scheme: runtime.NewScheme(),
+			Scheme:  runtime.NewScheme(),
+			CertDir: certDir,
+			Port:    webhookPort,
 		})
 		if err != nil {
 			return err
@@ -35,9 +83,38 @@ This will reconcile any events for the Combination and Template resources.
 			return err
 		}
 
+		maxStatusEvaluationsSize, err := cmd.Flags().GetInt("max-status-evaluations-size")
+		if err != nil {
+			return err
+		}
+
+		evaluationsNamespace, err := cmd.Flags().GetString("evaluations-namespace")
+		if err != nil {
+			return err
+		}
+
+		selectableKinds, err := cmd.Flags().GetStringSlice("selectable-kinds")
+		if err != nil {
+			return err
+		}
+		kinds := parseKinds(selectableKinds)
+
+		serviceAccount, err := cmd.Flags().GetString("service-account")
+		if err != nil {
+			return err
+		}
+		serviceAccountNamespace, serviceAccountName, err := parseServiceAccount(serviceAccount)
+		if err != nil {
+			return err
+		}
+
 		c, err := controller.NewController(
 			mgr.GetClient(),
 			ctrl.Log.V(verbosityLevel).WithName("run"),
+			controller.WithMaxStatusEvaluationsSize(maxStatusEvaluationsSize),
+			controller.WithEvaluationsNamespace(evaluationsNamespace),
+			controller.WithSelectableKinds(kinds...),
+			controller.WithServiceAccount(serviceAccountNamespace, serviceAccountName),
 		)
 		if err != nil {
 			return nil
@@ -47,7 +124,40 @@ This will reconcile any events for the Combination and Template resources.
 			return err
 		}
 
+		if certDir != "" {
+			w := webhook.NewWebhook(
+				mgr.GetClient(),
+				ctrl.Log.V(verbosityLevel).WithName("webhook"),
+				webhook.WithMaxProductSize(maxProductSize),
+				webhook.WithSelectableKinds(kinds...),
+			)
+			if err := w.ManageWith(mgr); err != nil {
+				return err
+			}
+		}
+
 		rootLog.Info("Starting Combo", "combo version", version.ComboVersion, "git commit", version.GitCommit, "kubernetes version", version.KubernetesVersion)
 		return mgr.Start(signals.SetupSignalHandler())
 	},
 }
+
+// parseKinds parses kinds given as Kind for core kinds or Kind.group otherwise
+func parseKinds(kinds []string) []schema.GroupKind {
+	parsed := make([]schema.GroupKind, 0, len(kinds))
+	for _, kind := range kinds {
+		parsed = append(parsed, schema.ParseGroupKind(strings.TrimSpace(kind)))
+	}
+	return parsed
+}
+
+// parseServiceAccount parses a ServiceAccount given as namespace/name, which may be empty
+func parseServiceAccount(serviceAccount string) (string, string, error) {
+	if serviceAccount == "" {
+		return "", "", nil
+	}
+	parts := strings.Split(serviceAccount, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid service account %q, must be namespace/name", serviceAccount)
+	}
+	return parts[0], parts[1], nil
+}
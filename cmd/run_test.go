@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestParseKinds(t *testing.T) {
+	require.Equal(t, []schema.GroupKind{{Kind: "Namespace"}, {Group: "apps", Kind: "Deployment"}}, parseKinds([]string{"Namespace", " Deployment.apps"}))
+	require.Empty(t, parseKinds(nil))
+}
+
+func TestParseServiceAccount(t *testing.T) {
+	for _, tt := range []struct {
+		name            string
+		serviceAccount  string
+		namespace, want string
+		err             bool
+	}{
+		{name: "parses namespace/name", serviceAccount: "combo/combo-applier", namespace: "combo", want: "combo-applier"},
+		{name: "leaves an empty service account unset", serviceAccount: ""},
+		{name: "rejects a name without namespace", serviceAccount: "combo-applier", err: true},
+		{name: "rejects extra segments", serviceAccount: "combo/combo/applier", err: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			namespace, name, err := parseServiceAccount(tt.serviceAccount)
+			if tt.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.namespace, namespace)
+			require.Equal(t, tt.want, name)
+		})
+	}
+}
@@ -5,7 +5,6 @@ go 1.17
 require (
 	github.com/go-logr/logr v0.4.0
 	github.com/golangci/golangci-lint v1.42.1
-	github.com/jinzhu/copier v0.3.2
 	github.com/onsi/ginkgo v1.16.4
 	github.com/onsi/gomega v1.15.0
 	github.com/sirupsen/logrus v1.8.1
@@ -17,6 +16,7 @@ require (
 	k8s.io/client-go v0.22.4
 	sigs.k8s.io/controller-runtime v0.10.2
 	sigs.k8s.io/controller-tools v0.7.0
+	sigs.k8s.io/yaml v1.2.0
 )
 
 require (
@@ -184,5 +184,4 @@ require (
 	mvdan.cc/lint v0.0.0-20170908181259-adc824a0674b // indirect
 	mvdan.cc/unparam v0.0.0-20210104141923-aac4ce9116a7 // indirect
 	sigs.k8s.io/structured-merge-diff/v4 v4.1.2 // indirect
-	sigs.k8s.io/yaml v1.2.0 // indirect
 )
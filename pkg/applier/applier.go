@@ -0,0 +1,469 @@
+package applier
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/operator-framework/combo/api/v1alpha1"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// FieldManager prefixes the field manager each combination applies its resources to the cluster as, so the
+// fields of a resource can be traced back to the combination that owns them
+const FieldManager = "combo"
+
+// maxLabelValueLength is the maximum length of a label value, which is shorter than the maximum length of a name
+const maxLabelValueLength = 63
+
+var (
+	ErrInvalidManifest = errors.New("invalid manifest")
+	ErrOutOfNamespace  = errors.New("resource is outside of the combination's namespace")
+	ErrConflict        = errors.New("resource is managed by another combination")
+	ErrUnowned         = errors.New("resource already exists without being managed by the combination")
+	ErrApplyFailed     = errors.New("failed to apply resources")
+	ErrPruneFailed     = errors.New("failed to prune resources")
+	ErrPlanFailed      = errors.New("failed to plan resources")
+
+	// ErrPruneForbidden is returned when every resource that failed to be pruned was forbidden, which retrying
+	// won't fix until access is granted again
+	ErrPruneForbidden = fmt.Errorf("%w: access is forbidden", ErrPruneFailed)
+)
+
+// New creates an applier that applies resources on behalf of the named combination
+func New(client client.Client, owner string, options ...ApplierOption) Applier {
+	a := Applier{
+		client: client,
+		owner:  owner,
+		label:  v1alpha1.CombinationLabel,
+	}
+	for _, option := range options {
+		option(&a)
+	}
+	return a
+}
+
+type Applier struct {
+	client client.Client
+	owner  string
+	label  string
+
+	// namespace confines every resource to it when set, using mapper to tell which resources are namespaced
+	namespace string
+	mapper    meta.RESTMapper
+}
+
+type ApplierOption func(*Applier)
+
+// WithNamespace confines the resources to the given namespace on behalf of a NamespacedCombination.
+// Resources without a namespace are applied to it, while cluster-scoped resources and resources within
+// other namespaces are reported as failed. Resources are labeled with NamespacedCombinationLabel instead,
+// since the owner's name is only unique within the namespace.
+func WithNamespace(namespace string, mapper meta.RESTMapper) ApplierOption {
+	return func(a *Applier) {
+		a.namespace = namespace
+		a.mapper = mapper
+		a.label = v1alpha1.NamespacedCombinationLabel
+	}
+}
+
+// Apply server-side applies each of the given manifests and returns the outcome for every one of them.
+// Manifests that cannot be decoded are reported as failed, but only failures returned from the cluster
+// result in an error, since retrying will not fix an invalid manifest.
+func (a *Applier) Apply(ctx context.Context, manifests []string) ([]v1alpha1.ResourceStatus, error) {
+	resources := []v1alpha1.ResourceStatus{}
+	var failed []string
+	for i, manifest := range manifests {
+		obj, err := Decode(manifest)
+		if err != nil {
+			resources = append(resources, v1alpha1.ResourceStatus{
+				Result:  v1alpha1.ResultFailed,
+				Message: fmt.Sprintf("evaluation %d: %s", i, err.Error()),
+			})
+			continue
+		}
+
+		if err := a.confine(obj); err != nil {
+			resources = append(resources, v1alpha1.ResourceStatus{
+				Result:  v1alpha1.ResultFailed,
+				Message: fmt.Sprintf("evaluation %d: %s", i, err.Error()),
+			})
+			if !errors.Is(err, ErrOutOfNamespace) {
+				failed = append(failed, fmt.Sprintf("evaluation %d: %s", i, err.Error()))
+			}
+			continue
+		}
+
+		a.labelOwned(obj)
+		resource := ResourceFor(obj)
+
+		// Leave resources the combination doesn't own alone rather than taking over their fields
+		live := &unstructured.Unstructured{}
+		live.SetGroupVersionKind(obj.GroupVersionKind())
+		err = a.client.Get(ctx, client.ObjectKeyFromObject(obj), live)
+		if err != nil && !apierrors.IsNotFound(err) {
+			resource.Result = v1alpha1.ResultFailed
+			resource.Message = err.Error()
+			resources = append(resources, resource)
+			failed = append(failed, fmt.Sprintf("%s %s: %s", resource.Kind, resource.Name, err.Error()))
+			continue
+		}
+		if err := a.conflict(live, err == nil); err != nil {
+			resource.Result = v1alpha1.ResultFailed
+			resource.Message = fmt.Sprintf("evaluation %d: %s", i, err.Error())
+			resources = append(resources, resource)
+			continue
+		}
+
+		if err := a.client.Patch(ctx, obj, client.Apply, client.FieldOwner(a.fieldManager()), client.ForceOwnership); err != nil {
+			resource.Result = v1alpha1.ResultFailed
+			resource.Message = err.Error()
+			failed = append(failed, fmt.Sprintf("%s %s: %s", resource.Kind, resource.Name, err.Error()))
+		} else {
+			resource.Result = v1alpha1.ResultApplied
+		}
+		resources = append(resources, resource)
+	}
+
+	if len(failed) > 0 {
+		return resources, fmt.Errorf("%w: %s", ErrApplyFailed, strings.Join(failed, ", "))
+	}
+	return resources, nil
+}
+
+// Prune deletes every resource in the previous inventory that is not part of the current one.
+// Resources that could not be deleted are returned as failed so they remain in the inventory
+// and are retried on the next reconcile.
+func (a *Applier) Prune(ctx context.Context, previous, current []v1alpha1.ResourceStatus) ([]v1alpha1.ResourceStatus, error) {
+	keep := map[string]struct{}{}
+	for _, resource := range current {
+		keep[keyFor(resource)] = struct{}{}
+	}
+
+	remaining := []v1alpha1.ResourceStatus{}
+	var failed []string
+	forbidden := true
+	for _, resource := range previous {
+		if resource.Kind == "" || resource.Name == "" {
+			// Nothing was created for evaluations that could not be decoded
+			continue
+		}
+		if _, ok := keep[keyFor(resource)]; ok {
+			continue
+		}
+
+		if err := a.Delete(ctx, resource); err != nil {
+			resource.Result = v1alpha1.ResultFailed
+			resource.Message = fmt.Sprintf("failed to prune: %s", err.Error())
+			remaining = append(remaining, resource)
+			failed = append(failed, fmt.Sprintf("%s %s: %s", resource.Kind, resource.Name, err.Error()))
+			forbidden = forbidden && apierrors.IsForbidden(err)
+		}
+	}
+
+	if len(failed) > 0 && forbidden {
+		return remaining, fmt.Errorf("%w: %s", ErrPruneForbidden, strings.Join(failed, ", "))
+	}
+	if len(failed) > 0 {
+		return remaining, fmt.Errorf("%w: %s", ErrPruneFailed, strings.Join(failed, ", "))
+	}
+	return remaining, nil
+}
+
+// Plan determines what applying the given manifests and pruning the previous inventory would change in the cluster,
+// without changing anything. Each manifest is applied with a server-side dry run, and the outcome compared with the
+// live object to tell whether it would be created, updated or left unchanged. As with Apply, only failures returned
+// from the cluster result in an error.
+func (a *Applier) Plan(ctx context.Context, manifests []string, previous []v1alpha1.ResourceStatus) (*v1alpha1.Plan, error) {
+	plan := &v1alpha1.Plan{}
+	var current []v1alpha1.ResourceStatus
+	var failed []string
+	for i, manifest := range manifests {
+		obj, err := Decode(manifest)
+		if err == nil {
+			err = a.confine(obj)
+		}
+		if err != nil {
+			plan.Failures = append(plan.Failures, v1alpha1.ResourceStatus{
+				Result:  v1alpha1.ResultFailed,
+				Message: fmt.Sprintf("evaluation %d: %s", i, err.Error()),
+			})
+			continue
+		}
+
+		a.labelOwned(obj)
+		resource := ResourceFor(obj)
+		current = append(current, resource)
+
+		live := &unstructured.Unstructured{}
+		live.SetGroupVersionKind(obj.GroupVersionKind())
+		err = a.client.Get(ctx, client.ObjectKeyFromObject(obj), live)
+		if err != nil && !apierrors.IsNotFound(err) {
+			resource.Result = v1alpha1.ResultFailed
+			resource.Message = err.Error()
+			plan.Failures = append(plan.Failures, resource)
+			failed = append(failed, fmt.Sprintf("%s %s: %s", resource.Kind, resource.Name, err.Error()))
+			continue
+		}
+		exists := err == nil
+		if err := a.conflict(live, exists); err != nil {
+			resource.Result = v1alpha1.ResultFailed
+			resource.Message = fmt.Sprintf("evaluation %d: %s", i, err.Error())
+			plan.Failures = append(plan.Failures, resource)
+			continue
+		}
+
+		if err := a.client.Patch(ctx, obj, client.Apply, client.FieldOwner(a.fieldManager()), client.ForceOwnership, client.DryRunAll); err != nil {
+			resource.Result = v1alpha1.ResultFailed
+			resource.Message = err.Error()
+			plan.Failures = append(plan.Failures, resource)
+			failed = append(failed, fmt.Sprintf("%s %s: %s", resource.Kind, resource.Name, err.Error()))
+			continue
+		}
+
+		switch fields := Diff(live, obj); {
+		case !exists:
+			plan.Creates++
+			plan.Changes = append(plan.Changes, v1alpha1.PlannedChange{Action: v1alpha1.ActionCreate, Resource: referenceFor(resource)})
+		case len(fields) > 0:
+			plan.Updates++
+			plan.Changes = append(plan.Changes, v1alpha1.PlannedChange{Action: v1alpha1.ActionUpdate, Resource: referenceFor(resource), Fields: fields})
+		default:
+			plan.Unchanged++
+		}
+	}
+
+	keep := map[string]struct{}{}
+	for _, resource := range current {
+		keep[keyFor(resource)] = struct{}{}
+	}
+	for _, resource := range previous {
+		if resource.Kind == "" || resource.Name == "" {
+			continue
+		}
+		if _, ok := keep[keyFor(resource)]; ok {
+			continue
+		}
+
+		obj, err := a.owned(ctx, resource)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s %s: %s", resource.Kind, resource.Name, err.Error()))
+			continue
+		}
+		if obj != nil {
+			plan.Deletes++
+			plan.Changes = append(plan.Changes, v1alpha1.PlannedChange{Action: v1alpha1.ActionDelete, Resource: referenceFor(resource)})
+		}
+	}
+
+	if len(failed) > 0 {
+		return plan, fmt.Errorf("%w: %s", ErrPlanFailed, strings.Join(failed, ", "))
+	}
+	return plan, nil
+}
+
+// Delete removes the given resource from the cluster if it is still owned by the applier's combination.
+// Resources that no longer exist or carry another combination's label are left untouched.
+func (a *Applier) Delete(ctx context.Context, resource v1alpha1.ResourceStatus) error {
+	obj, err := a.owned(ctx, resource)
+	if err != nil || obj == nil {
+		return err
+	}
+
+	if err := a.client.Delete(ctx, obj, client.PropagationPolicy("Background")); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// owned retrieves the given resource from the cluster, returning nil if it no longer exists
+// or carries another combination's label
+func (a *Applier) owned(ctx context.Context, resource v1alpha1.ResourceStatus) (*unstructured.Unstructured, error) {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(schema.FromAPIVersionAndKind(resource.APIVersion, resource.Kind))
+	if err := a.client.Get(ctx, client.ObjectKey{Namespace: resource.Namespace, Name: resource.Name}, obj); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+
+	if obj.GetLabels()[a.label] != LabelValue(a.owner) {
+		return nil, nil
+	}
+	return obj, nil
+}
+
+// conflict returns ErrConflict if the existing live object is labeled as generated by another combination, since both
+// combinations would keep overwriting each other's changes, and ErrUnowned if it isn't labeled at all, since adopting
+// it would have the combination prune an object it never created
+func (a *Applier) conflict(live *unstructured.Unstructured, exists bool) error {
+	if !exists {
+		return nil
+	}
+	for _, label := range []string{v1alpha1.CombinationLabel, v1alpha1.NamespacedCombinationLabel} {
+		value, ok := live.GetLabels()[label]
+		if ok && (label != a.label || value != LabelValue(a.owner)) {
+			return fmt.Errorf("%w: %s %s is labeled %s=%s", ErrConflict, live.GetKind(), live.GetName(), label, value)
+		}
+	}
+	if _, ok := live.GetLabels()[a.label]; !ok {
+		return fmt.Errorf("%w: %s %s is not labeled %s", ErrUnowned, live.GetKind(), live.GetName(), a.label)
+	}
+	return nil
+}
+
+// labelOwned labels the object so it can be traced back to the combination that generated it
+func (a *Applier) labelOwned(obj *unstructured.Unstructured) {
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[a.label] = LabelValue(a.owner)
+	obj.SetLabels(labels)
+}
+
+// fieldManager returns the field manager the combination applies its resources as, which tells combinations
+// and namespaced combinations of the same name apart
+func (a *Applier) fieldManager() string {
+	return fmt.Sprintf("%s/%s/%s", FieldManager, strings.TrimPrefix(a.label, "combo.io/"), LabelValue(a.owner))
+}
+
+// LabelValue returns the value combo labels the resources of the named combination with. Names too long for a
+// label value are truncated and suffixed with a hash of the whole name, which keeps the value unique.
+func LabelValue(name string) string {
+	if len(name) <= maxLabelValueLength {
+		return name
+	}
+	hash := sha256.Sum256([]byte(name))
+	suffix := "-" + hex.EncodeToString(hash[:])[:16]
+	return strings.TrimRight(name[:maxLabelValueLength-len(suffix)], ".-") + suffix
+}
+
+// confine ensures the object is a namespaced resource within the applier's namespace, if any,
+// defaulting the object's namespace to it
+func (a *Applier) confine(obj *unstructured.Unstructured) error {
+	if a.namespace == "" {
+		return nil
+	}
+
+	gvk := obj.GroupVersionKind()
+	mapping, err := a.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return fmt.Errorf("failed to determine whether %s is namespaced: %w", gvk.Kind, err)
+	}
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return fmt.Errorf("%w: %s %s is cluster-scoped", ErrOutOfNamespace, gvk.Kind, obj.GetName())
+	}
+
+	switch obj.GetNamespace() {
+	case "":
+		obj.SetNamespace(a.namespace)
+	case a.namespace:
+	default:
+		return fmt.Errorf("%w: %s %s is within the %s namespace instead of %s", ErrOutOfNamespace, gvk.Kind, obj.GetName(), obj.GetNamespace(), a.namespace)
+	}
+	return nil
+}
+
+// Decode converts a single manifest into an object that can be applied to the cluster
+func Decode(manifest string) (*unstructured.Unstructured, error) {
+	obj := &unstructured.Unstructured{}
+	if err := yaml.NewYAMLOrJSONDecoder(strings.NewReader(manifest), len(manifest)).Decode(&obj.Object); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidManifest, err.Error())
+	}
+
+	switch {
+	case obj.GetAPIVersion() == "" || obj.GetKind() == "":
+		return nil, fmt.Errorf("%w: apiVersion and kind must be set", ErrInvalidManifest)
+	case obj.GetName() == "":
+		return nil, fmt.Errorf("%w: metadata.name must be set", ErrInvalidManifest)
+	}
+
+	return obj, nil
+}
+
+// ResourceFor builds the status entry identifying the given object
+func ResourceFor(obj *unstructured.Unstructured) v1alpha1.ResourceStatus {
+	return v1alpha1.ResourceStatus{
+		APIVersion: obj.GetAPIVersion(),
+		Kind:       obj.GetKind(),
+		Namespace:  obj.GetNamespace(),
+		Name:       obj.GetName(),
+	}
+}
+
+// ignoredFields are set by the cluster whenever an object is written, so they're left out of its diff
+var ignoredFields = map[string]struct{}{
+	"status":                     {},
+	"metadata.managedFields":     {},
+	"metadata.resourceVersion":   {},
+	"metadata.generation":        {},
+	"metadata.creationTimestamp": {},
+	"metadata.uid":               {},
+	"metadata.selfLink":          {},
+}
+
+// Diff returns the sorted, dot separated paths of the fields that differ between the live object and the object
+// it would become, leaving out the fields set by the cluster. Lists are compared as a whole.
+func Diff(live, planned *unstructured.Unstructured) []string {
+	var fields []string
+	diff("", live.Object, planned.Object, &fields)
+	sort.Strings(fields)
+	return fields
+}
+
+func diff(path string, live, planned interface{}, fields *[]string) {
+	if _, ok := ignoredFields[path]; ok {
+		return
+	}
+
+	liveMap, liveIsMap := live.(map[string]interface{})
+	plannedMap, plannedIsMap := planned.(map[string]interface{})
+	if !liveIsMap || !plannedIsMap {
+		if !reflect.DeepEqual(live, planned) {
+			*fields = append(*fields, path)
+		}
+		return
+	}
+
+	for key, value := range liveMap {
+		diff(join(path, key), value, plannedMap[key], fields)
+	}
+	for key, value := range plannedMap {
+		if _, ok := liveMap[key]; !ok {
+			diff(join(path, key), nil, value, fields)
+		}
+	}
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// referenceFor identifies the object the resource was applied as
+func referenceFor(resource v1alpha1.ResourceStatus) v1alpha1.ResourceReference {
+	return v1alpha1.ResourceReference{
+		APIVersion: resource.APIVersion,
+		Kind:       resource.Kind,
+		Namespace:  resource.Namespace,
+		Name:       resource.Name,
+	}
+}
+
+// keyFor identifies a resource independently of the version it was applied with
+func keyFor(resource v1alpha1.ResourceStatus) string {
+	gk := schema.FromAPIVersionAndKind(resource.APIVersion, resource.Kind).GroupKind()
+	return fmt.Sprintf("%s/%s/%s", gk.String(), resource.Namespace, resource.Name)
+}
@@ -0,0 +1,388 @@
+package applier
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/operator-framework/combo/api/v1alpha1"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestDecode(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		manifest string
+		expected v1alpha1.ResourceStatus
+		err      error
+	}{
+		{
+			name: "decodes a namespaced resource",
+			manifest: `apiVersion: v1
+kind: ServiceAccount
+metadata:
+    name: baz
+    namespace: foo`,
+			expected: v1alpha1.ResourceStatus{
+				APIVersion: "v1",
+				Kind:       "ServiceAccount",
+				Namespace:  "foo",
+				Name:       "baz",
+			},
+		},
+		{
+			name: "decodes a cluster-scoped resource",
+			manifest: `apiVersion: v1
+kind: Namespace
+metadata:
+    name: foo`,
+			expected: v1alpha1.ResourceStatus{
+				APIVersion: "v1",
+				Kind:       "Namespace",
+				Name:       "foo",
+			},
+		},
+		{
+			name:     "rejects a manifest that is not an object",
+			manifest: `John: Snow`,
+			err:      ErrInvalidManifest,
+		},
+		{
+			name:     "rejects a scalar manifest",
+			manifest: `foo`,
+			err:      ErrInvalidManifest,
+		},
+		{
+			name: "rejects a resource without a name",
+			manifest: `apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+    generateName: feature-user-`,
+			err: ErrInvalidManifest,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			obj, err := Decode(tt.manifest)
+			require.ErrorIs(t, err, tt.err)
+			if tt.err != nil {
+				return
+			}
+
+			require.Equal(t, tt.expected, ResourceFor(obj))
+		})
+	}
+}
+
+func TestPrune(t *testing.T) {
+	configMap := func(name, owner string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels:    map[string]string{v1alpha1.CombinationLabel: owner},
+			},
+		}
+	}
+	resource := func(name string) v1alpha1.ResourceStatus {
+		return v1alpha1.ResourceStatus{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+			Namespace:  "default",
+			Name:       name,
+			Result:     v1alpha1.ResultApplied,
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cli := fake.NewClientBuilder().WithObjects(
+		configMap("kept", "owner"),
+		configMap("stale", "owner"),
+		configMap("foreign", "other"),
+	).Build()
+	a := New(cli, "owner")
+
+	previous := []v1alpha1.ResourceStatus{
+		resource("kept"),
+		resource("stale"),
+		resource("foreign"),
+		resource("missing"),
+		{Result: v1alpha1.ResultFailed, Message: "evaluation 3: invalid manifest"},
+	}
+	current := []v1alpha1.ResourceStatus{resource("kept")}
+
+	remaining, err := a.Prune(ctx, previous, current)
+	require.NoError(t, err)
+	require.Empty(t, remaining)
+
+	for name, exists := range map[string]bool{"kept": true, "stale": false, "foreign": true} {
+		err := cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: name}, &corev1.ConfigMap{})
+		if exists {
+			require.NoError(t, err, "%s should not have been pruned", name)
+		} else {
+			require.True(t, apierrors.IsNotFound(err), "%s should have been pruned", name)
+		}
+	}
+}
+
+// forbiddenClient forbids reading any resource, as when the ServiceAccount resources are managed as lost its access
+type forbiddenClient struct {
+	client.Client
+}
+
+func (c forbiddenClient) Get(_ context.Context, key client.ObjectKey, _ client.Object) error {
+	return apierrors.NewForbidden(corev1.Resource("configmaps"), key.Name, errors.New("access denied"))
+}
+
+func TestPruneForbidden(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	a := New(forbiddenClient{fake.NewClientBuilder().Build()}, "owner")
+	previous := []v1alpha1.ResourceStatus{{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "foo", Result: v1alpha1.ResultApplied}}
+
+	remaining, err := a.Prune(ctx, previous, nil)
+	require.ErrorIs(t, err, ErrPruneForbidden)
+	require.ErrorIs(t, err, ErrPruneFailed)
+	require.Len(t, remaining, 1)
+	require.Equal(t, v1alpha1.ResultFailed, remaining[0].Result)
+}
+
+func TestPlan(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	configMap := func(name, owner string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels:    map[string]string{v1alpha1.CombinationLabel: owner},
+			},
+		}
+	}
+	resource := func(name string) v1alpha1.ResourceStatus {
+		return v1alpha1.ResourceStatus{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+			Namespace:  "default",
+			Name:       name,
+			Result:     v1alpha1.ResultApplied,
+		}
+	}
+
+	cli := fake.NewClientBuilder().WithObjects(
+		configMap("stale", "owner"),
+		configMap("foreign", "other"),
+	).Build()
+	a := New(cli, "owner")
+
+	previous := []v1alpha1.ResourceStatus{resource("stale"), resource("foreign"), resource("missing")}
+	plan, err := a.Plan(ctx, []string{"kind: ConfigMap"}, previous)
+	require.NoError(t, err)
+	require.Equal(t, &v1alpha1.Plan{
+		Deletes: 1,
+		Changes: []v1alpha1.PlannedChange{{
+			Action:   v1alpha1.ActionDelete,
+			Resource: v1alpha1.ResourceReference{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "stale"},
+		}},
+		Failures: []v1alpha1.ResourceStatus{{
+			Result:  v1alpha1.ResultFailed,
+			Message: "evaluation 0: invalid manifest: apiVersion and kind must be set",
+		}},
+	}, plan)
+
+	// Planning leaves the cluster untouched
+	require.NoError(t, cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "stale"}, &corev1.ConfigMap{}))
+}
+
+func TestApplyConflict(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		labels map[string]string
+		err    error
+	}{
+		{
+			name:   "leaves resources of another combination alone",
+			labels: map[string]string{v1alpha1.CombinationLabel: "other"},
+			err:    ErrConflict,
+		},
+		{
+			name:   "leaves resources of a namespaced combination alone",
+			labels: map[string]string{v1alpha1.NamespacedCombinationLabel: "owner"},
+			err:    ErrConflict,
+		},
+		{
+			name:   "refuses to adopt resources that aren't labeled",
+			labels: map[string]string{"app": "foo"},
+			err:    ErrUnowned,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			cli := fake.NewClientBuilder().WithObjects(&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "foo",
+					Namespace: "default",
+					Labels:    tt.labels,
+				},
+			}).Build()
+			a := New(cli, "owner")
+
+			manifests := []string{"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: foo\n  namespace: default"}
+			resources, err := a.Apply(ctx, manifests)
+			require.NoError(t, err)
+			require.Len(t, resources, 1)
+			require.Equal(t, v1alpha1.ResultFailed, resources[0].Result)
+			require.Contains(t, resources[0].Message, tt.err.Error())
+
+			plan, err := a.Plan(ctx, manifests, nil)
+			require.NoError(t, err)
+			require.Len(t, plan.Failures, 1)
+			require.Contains(t, plan.Failures[0].Message, tt.err.Error())
+
+			configMap := &corev1.ConfigMap{}
+			require.NoError(t, cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "foo"}, configMap))
+			require.Equal(t, tt.labels, configMap.Labels)
+		})
+	}
+}
+
+func TestLabelValue(t *testing.T) {
+	require.Equal(t, "foo", LabelValue("foo"))
+
+	long := strings.Repeat("a", 60) + ".b" + strings.Repeat("c", 100)
+	value := LabelValue(long)
+	require.Len(t, value, maxLabelValueLength)
+	require.True(t, strings.HasPrefix(value, strings.Repeat("a", 46)))
+	require.NotEqual(t, value, LabelValue(long+"d"))
+	require.Empty(t, validation.IsValidLabelValue(value))
+}
+
+func TestDiff(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		live     map[string]interface{}
+		planned  map[string]interface{}
+		expected []string
+	}{
+		{
+			name: "finds changed, added and removed fields",
+			live: map[string]interface{}{
+				"data": map[string]interface{}{"changed": "old", "removed": "value", "same": "value"},
+			},
+			planned: map[string]interface{}{
+				"data": map[string]interface{}{"changed": "new", "added": "value", "same": "value"},
+			},
+			expected: []string{"data.added", "data.changed", "data.removed"},
+		},
+		{
+			name:     "compares lists as a whole",
+			live:     map[string]interface{}{"spec": map[string]interface{}{"ports": []interface{}{int64(80)}}},
+			planned:  map[string]interface{}{"spec": map[string]interface{}{"ports": []interface{}{int64(80), int64(443)}}},
+			expected: []string{"spec.ports"},
+		},
+		{
+			name: "ignores fields set by the cluster",
+			live: map[string]interface{}{
+				"metadata": map[string]interface{}{"name": "foo", "resourceVersion": "1", "generation": int64(1)},
+				"status":   map[string]interface{}{"ready": true},
+			},
+			planned: map[string]interface{}{
+				"metadata": map[string]interface{}{"name": "foo", "resourceVersion": "2", "generation": int64(2)},
+				"status":   map[string]interface{}{"ready": false},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			live := &unstructured.Unstructured{Object: tt.live}
+			planned := &unstructured.Unstructured{Object: tt.planned}
+			require.Equal(t, tt.expected, Diff(live, planned))
+		})
+	}
+}
+
+func TestConfine(t *testing.T) {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("ServiceAccount"), meta.RESTScopeNamespace)
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("Namespace"), meta.RESTScopeRoot)
+
+	for _, tt := range []struct {
+		name      string
+		manifest  string
+		namespace string
+		err       error
+	}{
+		{
+			name: "defaults the namespace",
+			manifest: `apiVersion: v1
+kind: ServiceAccount
+metadata:
+    name: baz`,
+			namespace: "foo",
+		},
+		{
+			name: "accepts resources within the namespace",
+			manifest: `apiVersion: v1
+kind: ServiceAccount
+metadata:
+    name: baz
+    namespace: foo`,
+			namespace: "foo",
+		},
+		{
+			name: "rejects resources within other namespaces",
+			manifest: `apiVersion: v1
+kind: ServiceAccount
+metadata:
+    name: baz
+    namespace: bar`,
+			err: ErrOutOfNamespace,
+		},
+		{
+			name: "rejects cluster-scoped resources",
+			manifest: `apiVersion: v1
+kind: Namespace
+metadata:
+    name: foo`,
+			err: ErrOutOfNamespace,
+		},
+		{
+			name: "fails on unknown kinds",
+			manifest: `apiVersion: v1
+kind: Unknown
+metadata:
+    name: foo`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			obj, err := Decode(tt.manifest)
+			require.NoError(t, err)
+
+			a := New(fake.NewClientBuilder().Build(), "owner", WithNamespace("foo", mapper))
+			err = a.confine(obj)
+			switch {
+			case tt.err != nil:
+				require.ErrorIs(t, err, tt.err)
+			case tt.namespace == "":
+				require.Error(t, err)
+				require.NotErrorIs(t, err, ErrOutOfNamespace)
+			default:
+				require.NoError(t, err)
+				require.Equal(t, tt.namespace, obj.GetNamespace())
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package combination
+
+import (
+	"fmt"
+	"sort"
+)
+
+// axis is a set of parameters that are iterated over together. Parameters that are
+// not zipped form an axis of their own, while each zipped group shares a single axis
+// so that the i-th value of every parameter in the group is always used together.
+type axis struct {
+	keys   []string
+	values [][]string // values[i] holds the i-th value of each key
+}
+
+// len returns the number of positions along the axis
+func (a axis) len() int {
+	return len(a.values)
+}
+
+// set assigns the values at the given position of the axis to the combination
+func (a axis) set(combination map[string]string, position int) {
+	for i, key := range a.keys {
+		combination[key] = a.values[position][i]
+	}
+}
+
+// buildAxes groups the args into the axes the stream iterates over. Each zip group
+// becomes a single axis and every remaining key becomes an axis of its own. Axes are
+// ordered by the position of their first key in the given order, followed by any keys
+// missing from it in lexical order.
+func buildAxes(args map[string][]string, zip [][]string, order []string) ([]axis, error) {
+	zipped := map[string][]string{}
+	for _, group := range zip {
+		if len(group) == 0 {
+			continue
+		}
+
+		for _, key := range group {
+			if _, ok := args[key]; !ok {
+				return nil, fmt.Errorf("%w: %q has no args", ErrInvalidZip, key)
+			}
+			if _, ok := zipped[key]; ok {
+				return nil, fmt.Errorf("%w: %q is zipped more than once", ErrInvalidZip, key)
+			}
+			if len(args[key]) != len(args[group[0]]) {
+				return nil, fmt.Errorf("%w: %q has %d values while %q has %d", ErrInvalidZip, key, len(args[key]), group[0], len(args[group[0]]))
+			}
+			zipped[key] = group
+		}
+	}
+
+	axes := []axis{}
+	added := map[string]struct{}{}
+	for _, key := range orderKeys(args, order) {
+		if _, ok := added[key]; ok {
+			continue
+		}
+
+		group, ok := zipped[key]
+		if !ok {
+			group = []string{key}
+		}
+
+		newAxis := axis{keys: group}
+		for i := range args[group[0]] {
+			row := make([]string, 0, len(group))
+			for _, groupKey := range group {
+				row = append(row, args[groupKey][i])
+			}
+			newAxis.values = append(newAxis.values, row)
+		}
+		for _, groupKey := range group {
+			added[groupKey] = struct{}{}
+		}
+		axes = append(axes, newAxis)
+	}
+
+	return axes, nil
+}
+
+// orderKeys returns the keys of args in the given order, followed by any keys
+// missing from it in lexical order. Keys in order without args are ignored.
+func orderKeys(args map[string][]string, order []string) []string {
+	keys := make([]string, 0, len(args))
+	ordered := map[string]struct{}{}
+	for _, key := range order {
+		if _, ok := args[key]; !ok {
+			continue
+		}
+		if _, ok := ordered[key]; ok {
+			continue
+		}
+		ordered[key] = struct{}{}
+		keys = append(keys, key)
+	}
+
+	remaining := []string{}
+	for key := range args {
+		if _, ok := ordered[key]; !ok {
+			remaining = append(remaining, key)
+		}
+	}
+	sort.Strings(remaining)
+
+	return append(keys, remaining...)
+}
@@ -3,32 +3,71 @@ package combination
 import (
 	"context"
 	"errors"
-
-	"github.com/jinzhu/copier"
+	"fmt"
 )
 
 // Specify which errors this package can return
 var (
 	ErrNoArgsSet             = errors.New("args not set")
 	ErrCombinationsNotSolved = errors.New("combinations not yet solved")
+	ErrInvalidZip            = errors.New("invalid zip")
+	ErrTooManyCombinations   = errors.New("too many combinations")
+	ErrOutOfRange            = errors.New("index out of range")
+	ErrExcluded              = errors.New("combination excluded")
+	ErrInvalidShard          = errors.New("invalid shard")
+	ErrInvalidStrategy       = errors.New("invalid strategy")
 )
 
 // Stream is a representation of all possible combinations
 // its args. That uses the Next() function to get each
 // combination. WithSolveAhead() ensures combinations are generated
-// all at once using. If not, it will solve iterativey with nextIterativeCombination()
+// all at once, otherwise each combination is solved as it is requested.
+//
+// Combinations are always returned in the same order: the values of the first
+// parameter change the slowest and those of the last parameter change the fastest,
+// with each parameter's values taken in the order they were given. Parameters are
+// ordered by WithParameterOrder(), or lexically by key if no order is given.
+// Strategies other than Product, set with WithStrategy(), only cover a subset of
+// the combinations, which are returned in the order they were generated in.
+//
+// Since the order is fixed, every combination has an index and can be accessed
+// directly with At() or used as the starting point of Next() with Seek(). When
+// the stream is sharded with WithShard(), indexes are relative to the shard.
 type Stream interface {
+	// Next returns the next combination of the stream, or nil once there are none left.
 	Next(ctx context.Context) (map[string]string, error)
+
+	// Len returns the number of combinations covered by the stream without enumerating
+	// them. Excluded combinations are included in the count.
+	Len() int
+
+	// At returns the combination at index i, where 0 <= i < Len(). ErrExcluded is
+	// returned in place of any combination matching the stream's exclusions.
+	At(i int) (map[string]string, error)
+
+	// Seek moves the stream so that the next call to Next() returns the combination at
+	// index i, or the first combination after it that isn't excluded. Seeking to Len()
+	// exhausts the stream.
+	Seek(i int) error
 }
 
 type stream struct {
-	combinations          []map[string]string
-	args                  map[string][]string // the raw data from the stream
-	solveAhead            bool                // if true the Next() function will solve combinations all at once using nextPreSolvedCombination()
-	solved                bool
-	combinationParameters []string // a list of the names of the parameters taken from the stream(args).
-	positionTree          []int    // array of integers that tracks the position of the next combination within args.
-	nextParameterToUpdate int      // an integer that represents the parameter we are currently looking at. Intializes as second to last value in parameters.
+	combinations []map[string]string // combinations solved ahead of time, indexed by position. Excluded combinations are nil.
+	args         map[string][]string // the raw data from the stream
+	exclusions   []map[string]string // partial combinations that should never be returned by the stream
+	zip          [][]string          // groups of keys whose values are iterated in lockstep
+	order        []string            // the order in which parameters are iterated, lexical if unset
+	solveAhead   bool                // if true the Next() function will solve combinations all at once using solve()
+	solved       bool
+	err          error    // an error found while constructing the stream, returned by Next()
+	axes         []axis   // the axes built from the stream(args) that are combined with each other.
+	strategy     Strategy // the strategy determining which combinations are covered, Product if unset.
+	plan         plan     // the positions along each axis of every combination covered by the strategy.
+	shard        int      // the index of the shard of combinations covered by the stream.
+	shards       int      // the number of shards the combinations are split into, unsharded if 0.
+	offset       int      // the index of the first combination covered by the stream.
+	length       int      // the number of combinations covered by the stream.
+	position     int      // the index of the combination Next() will consider next, relative to offset.
 }
 
 type StreamOption func(*stream)
@@ -39,14 +78,52 @@ func NewStream(options ...StreamOption) Stream {
 	for _, option := range options {
 		option(cs)
 	}
-	for key := range cs.args {
-		cs.combinationParameters = append(cs.combinationParameters, key)
-		cs.positionTree = append(cs.positionTree, 0)
+	cs.axes, cs.err = buildAxes(cs.args, cs.zip, cs.order)
+	if cs.err == nil && len(cs.axes) == 0 {
+		cs.err = ErrNoArgsSet
+	}
+	if cs.err != nil {
+		return cs
+	}
+
+	if cs.strategy == nil {
+		cs.strategy = Product
+	}
+	lengths := make([]int, 0, len(cs.axes))
+	for _, axis := range cs.axes {
+		lengths = append(lengths, axis.len())
+	}
+	cs.plan, cs.err = cs.strategy.plan(lengths)
+	if cs.err != nil {
+		return cs
+	}
+	cs.length = cs.plan.len()
+
+	if cs.shards != 0 {
+		if cs.shards < 0 || cs.shard < 0 || cs.shard >= cs.shards {
+			cs.err = fmt.Errorf("%w: shard %d of %d must be within [0, %d)", ErrInvalidShard, cs.shard, cs.shards, cs.shards)
+			cs.length = 0
+			return cs
+		}
+
+		// Spread the remainder over the first shards so no two shards differ by more than one combination
+		size, remainder := cs.length/cs.shards, cs.length%cs.shards
+		cs.offset = cs.shard*size + min(cs.shard, remainder)
+		cs.length = size
+		if cs.shard < remainder {
+			cs.length++
+		}
 	}
-	cs.nextParameterToUpdate = len(cs.combinationParameters) - 2 // Intializes nextParameterToUpdate to point to the second to last element in parameterList...
 	return cs
 }
 
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
 // WithArgs specifies which args to utilize in the new stream
 func WithArgs(args map[string][]string) StreamOption {
 	return func(cs *stream) {
@@ -54,6 +131,52 @@ func WithArgs(args map[string][]string) StreamOption {
 	}
 }
 
+// WithParameterOrder specifies the order in which the stream iterates over its parameters.
+// Keys of args missing from the given order are iterated over after those that are present,
+// in lexical order. A zip group is positioned by the first of its keys in the order.
+func WithParameterOrder(keys []string) StreamOption {
+	return func(cs *stream) {
+		cs.order = keys
+	}
+}
+
+// WithExclusions specifies partial combinations the stream should skip. A combination
+// is skipped if it contains every key and value of any of the given exclusions.
+func WithExclusions(exclusions []map[string]string) StreamOption {
+	return func(cs *stream) {
+		cs.exclusions = exclusions
+	}
+}
+
+// WithZip specifies groups of keys whose values are iterated in lockstep instead of
+// being combined with each other, i.e. the i-th value of every key in a group is only
+// ever combined with the i-th value of the other keys in that group. Each group is then
+// combined with the other groups and the remaining keys as usual. Every key in a group
+// must have the same number of values.
+func WithZip(groups ...[]string) StreamOption {
+	return func(cs *stream) {
+		cs.zip = append(cs.zip, groups...)
+	}
+}
+
+// WithShard splits the combinations into total contiguous shards of nearly equal size and
+// restricts the stream to the shard at index, where 0 <= index < total. Together, the shards
+// cover every combination exactly once, in the same order as an unsharded stream.
+func WithShard(index, total int) StreamOption {
+	return func(cs *stream) {
+		cs.shard = index
+		cs.shards = total
+	}
+}
+
+// WithStrategy specifies which combinations of the args the stream covers.
+// By default, the stream covers the Product of its args.
+func WithStrategy(strategy Strategy) StreamOption {
+	return func(cs *stream) {
+		cs.strategy = strategy
+	}
+}
+
 // WithSolveAhead specifies whether to solve before calling Next or All,
 // only occurs on the first call to Next or All. By using this, the Stream
 // will solve all possible combinations of its args which could take a lot
@@ -68,167 +191,118 @@ func WithSolveAhead(boolVar ...bool) StreamOption {
 	}
 }
 
-// updateParameterPositionsList() is a method that iterates cs.positionTree
-// as well as cs.nextParameterToUpdate to be updated to the next parameter/combination in the tree
-func (cs *stream) updateParameterPositionsList() {
-	// Iterates through position map in reverse
-	// looking for the first updatable value then breaks loop.
-	// Otherwise, resets values to zero, we know to update last parameter based off i.
-	var i int
-	for i = len(cs.positionTree) - 1; i > cs.nextParameterToUpdate; i-- {
-		if cs.positionTree[i]+1 < len(cs.args[cs.combinationParameters[i]]) {
-			cs.positionTree[i]++
-			break
+// excluded determines whether the given combination matches any of the stream's exclusions
+func (cs *stream) excluded(combination map[string]string) bool {
+	for _, exclusion := range cs.exclusions {
+		if len(exclusion) == 0 {
+			continue
 		}
-		cs.positionTree[i] = 0
-	}
 
-	// Checks to see if we need to update lastParameter based
-	// off value of i.
-	if i == cs.nextParameterToUpdate {
-		// Checks to see if this is the last argument of the parameter.
-		// Then updates parameter, and checks to see if the combination is solved.
-		if cs.positionTree[cs.nextParameterToUpdate]+1 == len(cs.args[cs.combinationParameters[cs.nextParameterToUpdate]]) {
-			cs.positionTree[cs.nextParameterToUpdate] = 0
-			cs.nextParameterToUpdate--
-		}
-		// If combination is not solved, we find the next nextParameterToUpdate,
-		// if nextParameterToUpdate has only 1 argument. Also, will mark as solved
-		// if reach end up parameters.
-		continueIterating := true
-		for !cs.solved && continueIterating && cs.nextParameterToUpdate != -1 {
-			if len(cs.args[cs.combinationParameters[cs.nextParameterToUpdate]]) == 1 {
-				cs.nextParameterToUpdate--
-				continue
+		matches := true
+		for key, val := range exclusion {
+			if combinationVal, ok := combination[key]; !ok || combinationVal != val {
+				matches = false
+				break
 			}
-			continueIterating = false
-			cs.positionTree[cs.nextParameterToUpdate]++
+		}
+		if matches {
+			return true
 		}
 	}
+	return false
 }
 
-// checkSolved() accesses whether or not a combination stream is solved,
-// if so it updates cs.solved variable to be true
-func (cs *stream) checkSolved() {
-	if cs.nextParameterToUpdate == -1 {
-		cs.solved = true
+// combinationAt looks up the position of each axis at the index and builds the combination found there
+func (cs *stream) combinationAt(i int) (map[string]string, error) {
+	combination := map[string]string{}
+	for x, position := range cs.plan.positions(i) {
+		cs.axes[x].set(combination, position)
 	}
-}
 
-// nextIterativeCombination() gets the next combination from the stream.
-// By using this, the stream will solve each combination
-// iteratively.
-func (cs *stream) nextIterativeCombination() (map[string]string, error) {
-	if cs.solved {
-		return nil, nil
+	if cs.excluded(combination) {
+		return nil, ErrExcluded
 	}
+	return combination, nil
+}
 
-	// Edge case: 0 parameters
-	if len(cs.combinationParameters) == 0 {
-		cs.solved = true
-		return nil, ErrNoArgsSet
+// solve takes the current stream and its args to solve all of their combinations
+func (cs *stream) solve() error {
+	// Return early if the stream is invalid or no args were sent
+	if cs.err != nil {
+		return cs.err
 	}
 
-	// Edge case: 1 parameter
-	if len(cs.combinationParameters) == 1 {
-		comboList := map[string]string{}
-		key := cs.combinationParameters[0]
-		comboList[key] = cs.args[cs.combinationParameters[0]][cs.positionTree[0]]
-		cs.positionTree[0]++
-		if cs.positionTree[0] == len(cs.args[cs.combinationParameters[0]]) {
-			cs.solved = true
+	combos := make([]map[string]string, cs.length)
+	for i := range combos {
+		combination, err := cs.combinationAt(cs.offset + i)
+		if err != nil && !errors.Is(err, ErrExcluded) {
+			return err
 		}
-		return comboList, nil
+		combos[i] = combination
 	}
 
-	// Generate a list of combinations from the positionTree
-	combinationList := map[string]string{}
-	for x := 0; x < len(cs.combinationParameters); x++ {
-		combination := cs.args[cs.combinationParameters[x]][cs.positionTree[x]]
-		parameter := cs.combinationParameters[x]
-		combinationList[parameter] = combination
-	}
+	cs.combinations = combos
+	cs.solved = true
+
+	return nil
+}
 
-	cs.updateParameterPositionsList()
-	cs.checkSolved()
-	return combinationList, nil
+// Len returns the number of combinations covered by the stream, including excluded combinations
+func (cs *stream) Len() int {
+	return cs.length
 }
 
-// nextPreSolvedCombination() generates all combinations at once.
-// Once combinations are generated it will return the next combination.
-func (cs *stream) nextPreSolvedCombination() (map[string]string, error) {
-	if cs.solveAhead && !cs.solved {
+// At returns the combination at index i. If solveAhead = true, all combinations
+// are solved on the first call and the combination is looked up from cs.combinations.
+func (cs *stream) At(i int) (map[string]string, error) {
+	if cs.err != nil {
+		return nil, cs.err
+	}
+	if i < 0 || i >= cs.length {
+		return nil, fmt.Errorf("%w: %d is not within [0, %d)", ErrOutOfRange, i, cs.length)
+	}
+
+	if !cs.solveAhead {
+		return cs.combinationAt(cs.offset + i)
+	}
+
+	if !cs.solved {
 		if err := cs.solve(); err != nil {
 			return nil, err
 		}
 	}
-
-	if len(cs.combinations) == 0 {
-		if cs.solved {
-			return nil, nil
-		}
-		return nil, ErrCombinationsNotSolved
+	if cs.combinations[i] == nil {
+		return nil, ErrExcluded
 	}
-	combo := cs.combinations[0]
-	cs.combinations = cs.combinations[1:]
-	return combo, nil
+	return cs.combinations[i], nil
 }
 
-// solve takes the current stream and its args to solve their combinations
-func (cs *stream) solve() error {
-	// Return early if no args were sent
-	if len(cs.args) == 0 {
-		return ErrNoArgsSet
-	}
-
-	combos := []map[string]string{}
-
-	// Create holder arrays to process the incoming args
-	arrays := [][]string{}
-	replacements := []string{}
-	for key, val := range cs.args {
-		arrays = append(arrays, val)
-		replacements = append(replacements, key)
-	}
-
-	// Define max length of each combo
-	max := len(arrays) - 1
-
-	// Define recursive function for getting combinations
-	var err error
-	var recurse func(combo map[string]string, i int)
-	recurse = func(combo map[string]string, i int) {
-		for _, val := range arrays[i] {
-			combo[replacements[i]] = val
-			if i == max {
-				// Append a copy of the map to the combos
-				comboCopy := map[string]string{}
-				err = copier.Copy(&comboCopy, &combo)
-				combos = append(combos, comboCopy)
-			} else {
-				recurse(combo, i+1)
-			}
-		}
+// Seek moves the stream to the combination at index i
+func (cs *stream) Seek(i int) error {
+	if cs.err != nil {
+		return cs.err
 	}
-
-	// Recurse to produce combinations
-	recurse(map[string]string{}, 0)
-	if err != nil {
-		return err
+	if i < 0 || i > cs.length {
+		return fmt.Errorf("%w: %d is not within [0, %d]", ErrOutOfRange, i, cs.length)
 	}
-
-	cs.combinations = combos
-	cs.solved = true
-
+	cs.position = i
 	return nil
 }
 
-// Next generates the combinations iteartively. If the solveAhead = true,
-// the combinations are generated all at once then the next one will be returned
-// within the list of cs.combinations.
+// Next returns the combination at the stream's position and advances it,
+// skipping over any excluded combinations.
 func (cs *stream) Next(ctx context.Context) (map[string]string, error) {
-	if cs.solveAhead {
-		return cs.nextPreSolvedCombination()
+	if cs.err != nil {
+		return nil, cs.err
+	}
+
+	for cs.position < cs.length {
+		combination, err := cs.At(cs.position)
+		cs.position++
+		if errors.Is(err, ErrExcluded) {
+			continue
+		}
+		return combination, err
 	}
-	return cs.nextIterativeCombination()
+	return nil, nil
 }
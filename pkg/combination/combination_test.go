@@ -109,3 +109,498 @@ func TestNext(t *testing.T) {
 		})
 	}
 }
+
+func TestNextWithExclusions(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		input      map[string][]string
+		exclusions []map[string]string
+		expected   []map[string]string
+	}{
+		{
+			name:  "excludes a full combination",
+			input: testdata.CombinationInput,
+			exclusions: []map[string]string{
+				{"TEST1": "foo", "TEST2": "zip", "TEST3": "bip"},
+			},
+			expected: testdata.CombinationOutput[1:],
+		},
+		{
+			name:  "excludes every combination matching a partial combination",
+			input: testdata.CombinationInput,
+			exclusions: []map[string]string{
+				{"TEST1": "bar", "TEST3": "bap"},
+			},
+			expected: []map[string]string{
+				{"TEST1": "foo", "TEST2": "zip", "TEST3": "bip"},
+				{"TEST1": "foo", "TEST2": "zap", "TEST3": "bip"},
+				{"TEST1": "bar", "TEST2": "zip", "TEST3": "bip"},
+				{"TEST1": "bar", "TEST2": "zap", "TEST3": "bip"},
+				{"TEST1": "foo", "TEST2": "zip", "TEST3": "bap"},
+				{"TEST1": "foo", "TEST2": "zap", "TEST3": "bap"},
+			},
+		},
+		{
+			name:  "excludes combinations matching any exclusion",
+			input: testdata.OneParameterCombinationInput,
+			exclusions: []map[string]string{
+				{"TEST1": "foo"},
+				{"TEST1": "baz"},
+			},
+			expected: []map[string]string{
+				{"TEST1": "bar"},
+			},
+		},
+		{
+			name:  "ignores exclusions for unknown keys and empty exclusions",
+			input: testdata.OneParameterCombinationInput,
+			exclusions: []map[string]string{
+				{"NOT_PRESENT": "foo"},
+				{},
+			},
+			expected: testdata.OneParameterCombinationOutput,
+		},
+		{
+			name:  "excludes every combination",
+			input: testdata.OneParameterCombinationInput,
+			exclusions: []map[string]string{
+				{"TEST1": "foo"},
+				{"TEST1": "bar"},
+			},
+			expected: nil,
+		},
+	} {
+		for _, solveAhead := range []bool{false, true} {
+			t.Run(tt.name, func(t *testing.T) {
+				combinationStream := NewStream(
+					WithArgs(tt.input),
+					WithExclusions(tt.exclusions),
+					WithSolveAhead(solveAhead),
+				)
+
+				var got []map[string]string
+
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+
+				for {
+					next, err := combinationStream.Next(ctx)
+					require.NoError(t, err, "error received while processing combination stream")
+
+					if next == nil {
+						break
+					}
+
+					got = append(got, next)
+				}
+				require.ElementsMatch(t, tt.expected, got, "combos generated incorrectly")
+			})
+		}
+	}
+}
+
+func TestNextWithZip(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		input    map[string][]string
+		zip      [][]string
+		expected []map[string]string
+		err      error
+	}{
+		{
+			name: "zips a group and combines it with the remaining args",
+			input: map[string][]string{
+				"NAMESPACE": {"foo", "bar"},
+				"QUOTA":     {"1Gi", "2Gi"},
+				"SIZE":      {"small", "large"},
+			},
+			zip: [][]string{{"NAMESPACE", "QUOTA"}},
+			expected: []map[string]string{
+				{"NAMESPACE": "foo", "QUOTA": "1Gi", "SIZE": "small"},
+				{"NAMESPACE": "foo", "QUOTA": "1Gi", "SIZE": "large"},
+				{"NAMESPACE": "bar", "QUOTA": "2Gi", "SIZE": "small"},
+				{"NAMESPACE": "bar", "QUOTA": "2Gi", "SIZE": "large"},
+			},
+		},
+		{
+			name: "zips every arg",
+			input: map[string][]string{
+				"NAMESPACE": {"foo", "bar", "baz"},
+				"QUOTA":     {"1Gi", "2Gi", "3Gi"},
+			},
+			zip: [][]string{{"NAMESPACE", "QUOTA"}},
+			expected: []map[string]string{
+				{"NAMESPACE": "foo", "QUOTA": "1Gi"},
+				{"NAMESPACE": "bar", "QUOTA": "2Gi"},
+				{"NAMESPACE": "baz", "QUOTA": "3Gi"},
+			},
+		},
+		{
+			name: "crosses multiple zipped groups",
+			input: map[string][]string{
+				"NAMESPACE": {"foo", "bar"},
+				"QUOTA":     {"1Gi", "2Gi"},
+				"GROUP":     {"sre", "dev"},
+				"ROLE":      {"admin", "view"},
+			},
+			zip: [][]string{{"NAMESPACE", "QUOTA"}, {"GROUP", "ROLE"}},
+			expected: []map[string]string{
+				{"NAMESPACE": "foo", "QUOTA": "1Gi", "GROUP": "sre", "ROLE": "admin"},
+				{"NAMESPACE": "foo", "QUOTA": "1Gi", "GROUP": "dev", "ROLE": "view"},
+				{"NAMESPACE": "bar", "QUOTA": "2Gi", "GROUP": "sre", "ROLE": "admin"},
+				{"NAMESPACE": "bar", "QUOTA": "2Gi", "GROUP": "dev", "ROLE": "view"},
+			},
+		},
+		{
+			name: "rejects a group with mismatched lengths",
+			input: map[string][]string{
+				"NAMESPACE": {"foo", "bar"},
+				"QUOTA":     {"1Gi"},
+			},
+			zip: [][]string{{"NAMESPACE", "QUOTA"}},
+			err: ErrInvalidZip,
+		},
+		{
+			name: "rejects a group with unknown keys",
+			input: map[string][]string{
+				"NAMESPACE": {"foo", "bar"},
+			},
+			zip: [][]string{{"NAMESPACE", "QUOTA"}},
+			err: ErrInvalidZip,
+		},
+		{
+			name: "rejects a key zipped more than once",
+			input: map[string][]string{
+				"NAMESPACE": {"foo", "bar"},
+				"QUOTA":     {"1Gi", "2Gi"},
+			},
+			zip: [][]string{{"NAMESPACE", "QUOTA"}, {"NAMESPACE"}},
+			err: ErrInvalidZip,
+		},
+	} {
+		for _, solveAhead := range []bool{false, true} {
+			t.Run(tt.name, func(t *testing.T) {
+				combinationStream := NewStream(
+					WithArgs(tt.input),
+					WithZip(tt.zip...),
+					WithSolveAhead(solveAhead),
+				)
+
+				var got []map[string]string
+
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+
+				for {
+					next, err := combinationStream.Next(ctx)
+					require.ErrorIs(t, err, tt.err)
+
+					if next == nil {
+						break
+					}
+
+					got = append(got, next)
+				}
+				require.ElementsMatch(t, tt.expected, got, "combos generated incorrectly")
+			})
+		}
+	}
+}
+
+func TestNextOrder(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		input    map[string][]string
+		order    []string
+		zip      [][]string
+		expected []map[string]string
+	}{
+		{
+			name:     "iterates parameters in lexical order by default",
+			input:    testdata.LongCombinationInput,
+			expected: testdata.LongCombinationOutput,
+		},
+		{
+			name:     "iterates parameters in the given order",
+			input:    testdata.CombinationInput,
+			order:    []string{"TEST3", "TEST1", "TEST2"},
+			expected: testdata.CombinationOutput,
+		},
+		{
+			name:     "iterates parameters missing from the given order last",
+			input:    testdata.CombinationInput,
+			order:    []string{"TEST3", "NOT_PRESENT"},
+			expected: testdata.CombinationOutput,
+		},
+		{
+			name: "positions a zip group by its first key in the given order",
+			input: map[string][]string{
+				"NAMESPACE": {"foo", "bar"},
+				"QUOTA":     {"1Gi", "2Gi"},
+				"SIZE":      {"small", "large"},
+			},
+			order: []string{"SIZE", "QUOTA"},
+			zip:   [][]string{{"NAMESPACE", "QUOTA"}},
+			expected: []map[string]string{
+				{"NAMESPACE": "foo", "QUOTA": "1Gi", "SIZE": "small"},
+				{"NAMESPACE": "bar", "QUOTA": "2Gi", "SIZE": "small"},
+				{"NAMESPACE": "foo", "QUOTA": "1Gi", "SIZE": "large"},
+				{"NAMESPACE": "bar", "QUOTA": "2Gi", "SIZE": "large"},
+			},
+		},
+	} {
+		for _, solveAhead := range []bool{false, true} {
+			t.Run(tt.name, func(t *testing.T) {
+				combinationStream := NewStream(
+					WithArgs(tt.input),
+					WithParameterOrder(tt.order),
+					WithZip(tt.zip...),
+					WithSolveAhead(solveAhead),
+				)
+
+				var got []map[string]string
+
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+
+				for {
+					next, err := combinationStream.Next(ctx)
+					require.NoError(t, err, "error received while processing combination stream")
+
+					if next == nil {
+						break
+					}
+
+					got = append(got, next)
+				}
+				require.Equal(t, tt.expected, got, "combos generated in the wrong order")
+			})
+		}
+	}
+}
+
+func TestLen(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		input      map[string][]string
+		zip        [][]string
+		exclusions []map[string]string
+		expected   int
+	}{
+		{
+			name:     "empty map input",
+			input:    map[string][]string{},
+			expected: 0,
+		},
+		{
+			name:     "standard set of args",
+			input:    testdata.CombinationInput,
+			expected: 8,
+		},
+		{
+			name:     "standard set of long args",
+			input:    testdata.LongCombinationInput,
+			expected: 12,
+		},
+		{
+			name:     "zipped args",
+			input:    testdata.CombinationInput,
+			zip:      [][]string{{"TEST1", "TEST2"}},
+			expected: 4,
+		},
+		{
+			name:       "excluded combinations are counted",
+			input:      testdata.CombinationInput,
+			exclusions: []map[string]string{{"TEST1": "foo"}},
+			expected:   8,
+		},
+		{
+			name:     "args without values",
+			input:    map[string][]string{"TEST1": {"foo"}, "TEST2": {}},
+			expected: 0,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			combinationStream := NewStream(
+				WithArgs(tt.input),
+				WithZip(tt.zip...),
+				WithExclusions(tt.exclusions),
+			)
+			require.Equal(t, tt.expected, combinationStream.Len())
+		})
+	}
+}
+
+func TestAt(t *testing.T) {
+	for _, solveAhead := range []bool{false, true} {
+		combinationStream := NewStream(
+			WithArgs(testdata.LongCombinationInput),
+			WithExclusions([]map[string]string{{"TEST6": "zap"}}),
+			WithSolveAhead(solveAhead),
+		)
+
+		for i, expected := range testdata.LongCombinationOutput {
+			got, err := combinationStream.At(i)
+			if expected["TEST6"] == "zap" {
+				require.ErrorIs(t, err, ErrExcluded)
+				require.Nil(t, got)
+				continue
+			}
+			require.NoError(t, err)
+			require.Equal(t, expected, got, "combination %d decoded incorrectly", i)
+		}
+
+		_, err := combinationStream.At(-1)
+		require.ErrorIs(t, err, ErrOutOfRange)
+
+		_, err = combinationStream.At(combinationStream.Len())
+		require.ErrorIs(t, err, ErrOutOfRange)
+	}
+
+	_, err := NewStream().At(0)
+	require.ErrorIs(t, err, ErrNoArgsSet)
+}
+
+func TestSeek(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		seek       int
+		exclusions []map[string]string
+		expected   []map[string]string
+		err        error
+	}{
+		{
+			name:     "resumes from an offset",
+			seek:     9,
+			expected: testdata.LongCombinationOutput[9:],
+		},
+		{
+			name:     "resumes from the start",
+			seek:     0,
+			expected: testdata.LongCombinationOutput,
+		},
+		{
+			name:       "resumes from the first combination that is not excluded",
+			seek:       1,
+			exclusions: []map[string]string{{"TEST3": "bip", "TEST6": "zap"}, {"TEST3": "bip", "TEST6": "zop"}},
+			expected: append(
+				append([]map[string]string{}, testdata.LongCombinationOutput[3:7]...),
+				testdata.LongCombinationOutput[9:]...,
+			),
+		},
+		{
+			name:     "exhausts the stream",
+			seek:     12,
+			expected: nil,
+		},
+		{
+			name: "rejects an index out of range",
+			seek: 13,
+			err:  ErrOutOfRange,
+		},
+	} {
+		for _, solveAhead := range []bool{false, true} {
+			t.Run(tt.name, func(t *testing.T) {
+				combinationStream := NewStream(
+					WithArgs(testdata.LongCombinationInput),
+					WithExclusions(tt.exclusions),
+					WithSolveAhead(solveAhead),
+				)
+
+				err := combinationStream.Seek(tt.seek)
+				require.ErrorIs(t, err, tt.err)
+				if tt.err != nil {
+					return
+				}
+
+				var got []map[string]string
+
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+
+				for {
+					next, err := combinationStream.Next(ctx)
+					require.NoError(t, err, "error received while processing combination stream")
+
+					if next == nil {
+						break
+					}
+
+					got = append(got, next)
+				}
+				require.Equal(t, tt.expected, got, "combos generated incorrectly after seeking")
+			})
+		}
+	}
+}
+
+func TestShard(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		input    map[string][]string
+		shards   int
+		expected []int
+	}{
+		{
+			name:     "splits combinations evenly",
+			input:    testdata.LongCombinationInput,
+			shards:   4,
+			expected: []int{3, 3, 3, 3},
+		},
+		{
+			name:     "spreads the remainder over the first shards",
+			input:    testdata.LongCombinationInput,
+			shards:   5,
+			expected: []int{3, 3, 2, 2, 2},
+		},
+		{
+			name:     "leaves shards empty when there are more shards than combinations",
+			input:    testdata.OneParameterCombinationInput,
+			shards:   3,
+			expected: []int{1, 1, 0},
+		},
+	} {
+		for _, solveAhead := range []bool{false, true} {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+
+				var got []map[string]string
+				for shard := 0; shard < tt.shards; shard++ {
+					combinationStream := NewStream(
+						WithArgs(tt.input),
+						WithShard(shard, tt.shards),
+						WithSolveAhead(solveAhead),
+					)
+					require.Equal(t, tt.expected[shard], combinationStream.Len(), "shard %d has the wrong size", shard)
+
+					for {
+						next, err := combinationStream.Next(ctx)
+						require.NoError(t, err, "error received while processing combination stream")
+
+						if next == nil {
+							break
+						}
+
+						got = append(got, next)
+					}
+				}
+
+				// Together, the shards cover every combination in order
+				var expected []map[string]string
+				unsharded := NewStream(WithArgs(tt.input))
+				for i := 0; i < unsharded.Len(); i++ {
+					combination, err := unsharded.At(i)
+					require.NoError(t, err)
+					expected = append(expected, combination)
+				}
+				require.Equal(t, expected, got, "shards do not cover every combination")
+			})
+		}
+	}
+
+	for _, shard := range [][2]int{{-1, 2}, {2, 2}, {0, -1}} {
+		_, err := NewStream(WithArgs(testdata.CombinationInput), WithShard(shard[0], shard[1])).Next(context.Background())
+		require.ErrorIs(t, err, ErrInvalidShard, "shard %d of %d should be invalid", shard[0], shard[1])
+	}
+}
@@ -0,0 +1,297 @@
+package combination
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Strategy determines which combinations of the stream's args are covered by it.
+// Product covers every combination, while NWise only covers enough combinations
+// for every set of values of any n parameters to appear together at least once.
+type Strategy interface {
+	// plan lays out the combinations covered for axes of the given lengths
+	plan(lengths []int) (plan, error)
+}
+
+// plan maps the index of each combination covered by a stream to a position along each axis
+type plan interface {
+	// len returns the number of combinations covered by the plan
+	len() int
+	// positions returns the position along each axis of the combination at index i
+	positions(i int) []int
+}
+
+const (
+	// MaxStrength is the largest strength NWise accepts. The cost of planning grows exponentially with the
+	// strength, while covering arrays of higher strengths are rarely any smaller than the full product.
+	MaxStrength = 6
+
+	// maxTuples bounds the number of value tuples NWise tracks while planning, which otherwise grows
+	// with the number of subsets of parameters of the given strength and the product of their lengths
+	maxTuples = 1 << 18
+)
+
+var (
+	// Product covers every combination of the stream's args.
+	Product Strategy = product{}
+
+	// Pairwise covers every pair of values of any two parameters at least once.
+	Pairwise = NWise(2)
+)
+
+// ParseStrategy returns the strategy with the given case-insensitive name, which is
+// one of product, pairwise or nwise. The strength is only used by nwise and is the
+// number of parameters whose values must all appear together. Product is returned
+// if no name is given.
+func ParseStrategy(name string, strength int) (Strategy, error) {
+	switch strings.ToLower(name) {
+	case "", "product":
+		return Product, nil
+	case "pairwise":
+		return Pairwise, nil
+	case "nwise":
+		if strength < 1 || strength > MaxStrength {
+			return nil, fmt.Errorf("%w: nwise requires a strength between 1 and %d, got %d", ErrInvalidStrategy, MaxStrength, strength)
+		}
+		return NWise(strength), nil
+	default:
+		return nil, fmt.Errorf("%w: unknown strategy %q", ErrInvalidStrategy, name)
+	}
+}
+
+type product struct{}
+
+type productPlan struct {
+	lengths []int
+	strides []int // the number of combinations between consecutive positions of each axis.
+	length  int
+}
+
+// plan treats each axis as a digit of a mixed-radix number where the last axis is the least
+// significant, so the stride of an axis is the product of the lengths of all axes after it
+func (product) plan(lengths []int) (plan, error) {
+	p := &productPlan{
+		lengths: lengths,
+		strides: make([]int, len(lengths)),
+		length:  1,
+	}
+	for i := len(lengths) - 1; i >= 0; i-- {
+		p.strides[i] = p.length
+		if lengths[i] > 0 && p.length > math.MaxInt/lengths[i] {
+			return nil, fmt.Errorf("%w: the number of combinations overflows", ErrTooManyCombinations)
+		}
+		p.length *= lengths[i]
+	}
+	return p, nil
+}
+
+func (p *productPlan) len() int {
+	return p.length
+}
+
+func (p *productPlan) positions(i int) []int {
+	positions := make([]int, len(p.lengths))
+	for x := range p.lengths {
+		positions[x] = (i / p.strides[x]) % p.lengths[x]
+	}
+	return positions
+}
+
+type nwise struct {
+	strength int
+}
+
+// NWise covers every set of values of any n parameters at least once, which is
+// usually far fewer combinations than the full product. When there are no more
+// than n parameters, every combination is covered, just like with Product.
+//
+// Exclusions are applied to the combinations once they are planned, so excluded
+// combinations are skipped rather than replaced. Any set of values that only an
+// excluded combination covered is no longer covered by the stream.
+func NWise(n int) Strategy {
+	return nwise{strength: n}
+}
+
+type rowsPlan struct {
+	rows [][]int
+}
+
+func (p *rowsPlan) len() int {
+	return len(p.rows)
+}
+
+func (p *rowsPlan) positions(i int) []int {
+	return p.rows[i]
+}
+
+// tupleSet tracks which value tuples of a set of axes have been covered
+type tupleSet struct {
+	axes      []int
+	strides   []int
+	covered   []bool
+	uncovered int
+}
+
+func newTupleSet(axes []int, lengths []int) *tupleSet {
+	ts := &tupleSet{
+		axes:    axes,
+		strides: make([]int, len(axes)),
+	}
+	size := 1
+	for i := len(axes) - 1; i >= 0; i-- {
+		ts.strides[i] = size
+		size *= lengths[axes[i]]
+	}
+	ts.covered = make([]bool, size)
+	ts.uncovered = size
+	return ts
+}
+
+// index returns the index of the tuple found in row, or false if any of its axes are unassigned
+func (ts *tupleSet) index(row []int) (int, bool) {
+	index := 0
+	for i, axis := range ts.axes {
+		if row[axis] < 0 {
+			return 0, false
+		}
+		index += row[axis] * ts.strides[i]
+	}
+	return index, true
+}
+
+// plan builds a covering array greedily: each row starts from the first uncovered tuple and
+// assigns the remaining axes, in order, the value covering the most uncovered tuples so far.
+// Ties are broken by the lowest position, so the same lengths always result in the same plan.
+func (s nwise) plan(lengths []int) (plan, error) {
+	if s.strength < 1 || s.strength > MaxStrength {
+		return nil, fmt.Errorf("%w: nwise requires a strength between 1 and %d, got %d", ErrInvalidStrategy, MaxStrength, s.strength)
+	}
+	for _, length := range lengths {
+		if length == 0 {
+			return &rowsPlan{}, nil
+		}
+	}
+
+	// Every combination has to be covered when the strength spans every axis
+	if s.strength >= len(lengths) {
+		return product{}.plan(lengths)
+	}
+
+	// Bound the number of tuples to track before allocating any of them
+	if count, ok := binomial(len(lengths), s.strength); !ok || count > maxTuples {
+		return nil, fmt.Errorf("%w: covering every set of values of any %d of %d parameters requires tracking more than %d tuples", ErrTooManyCombinations, s.strength, len(lengths), maxTuples)
+	}
+	setAxes := subsets(len(lengths), s.strength)
+	total := 0
+	for _, axes := range setAxes {
+		size := 1
+		for _, axis := range axes {
+			size *= lengths[axis]
+			if size > maxTuples {
+				break
+			}
+		}
+		if total += size; total > maxTuples {
+			return nil, fmt.Errorf("%w: covering every set of values of any %d of %d parameters requires tracking more than %d tuples", ErrTooManyCombinations, s.strength, len(lengths), maxTuples)
+		}
+	}
+
+	var sets []*tupleSet
+	for _, axes := range setAxes {
+		sets = append(sets, newTupleSet(axes, lengths))
+	}
+
+	// Index the tuple sets each axis takes part in
+	setsOf := make([][]*tupleSet, len(lengths))
+	for _, ts := range sets {
+		for _, axis := range ts.axes {
+			setsOf[axis] = append(setsOf[axis], ts)
+		}
+	}
+
+	p := &rowsPlan{}
+	for _, seed := range sets {
+		for seed.uncovered > 0 {
+			row := make([]int, len(lengths))
+			for i := range row {
+				row[i] = -1
+			}
+
+			// Seed the row with the first uncovered tuple
+			for index, covered := range seed.covered {
+				if covered {
+					continue
+				}
+				for i, axis := range seed.axes {
+					row[axis] = (index / seed.strides[i]) % lengths[axis]
+				}
+				break
+			}
+
+			// Greedily assign the remaining axes
+			for axis := range row {
+				if row[axis] >= 0 {
+					continue
+				}
+
+				best, bestGain := 0, -1
+				for position := 0; position < lengths[axis]; position++ {
+					row[axis] = position
+					gain := 0
+					for _, ts := range setsOf[axis] {
+						if index, ok := ts.index(row); ok && !ts.covered[index] {
+							gain++
+						}
+					}
+					if gain > bestGain {
+						best, bestGain = position, gain
+					}
+				}
+				row[axis] = best
+			}
+
+			for _, ts := range sets {
+				index, _ := ts.index(row)
+				if !ts.covered[index] {
+					ts.covered[index] = true
+					ts.uncovered--
+				}
+			}
+			p.rows = append(p.rows, row)
+		}
+	}
+
+	return p, nil
+}
+
+// binomial returns the number of subsets of size k of n elements, or false if it overflows
+func binomial(n, k int) (int, bool) {
+	result := 1
+	for i := 0; i < k; i++ {
+		if result > math.MaxInt/(n-i) {
+			return 0, false
+		}
+		result = result * (n - i) / (i + 1)
+	}
+	return result, true
+}
+
+// subsets returns every subset of size k of the integers in [0, n) in lexical order
+func subsets(n, k int) [][]int {
+	var result [][]int
+	subset := make([]int, k)
+	var recurse func(start, i int)
+	recurse = func(start, i int) {
+		if i == k {
+			result = append(result, append([]int{}, subset...))
+			return
+		}
+		for x := start; x < n; x++ {
+			subset[i] = x
+			recurse(x+1, i+1)
+		}
+	}
+	recurse(0, 0)
+	return result
+}
@@ -0,0 +1,233 @@
+package combination
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	testdata "github.com/operator-framework/combo/test/assets/combination"
+	"github.com/stretchr/testify/require"
+)
+
+var strategyInput = map[string][]string{
+	"OS":       {"linux", "windows", "darwin"},
+	"ARCH":     {"amd64", "arm64", "s390x"},
+	"VERSION":  {"1.20", "1.21", "1.22"},
+	"DATABASE": {"postgres", "mysql", "sqlite"},
+	"CACHE":    {"on", "off"},
+}
+
+// requireCovered asserts that every set of values of any strength keys appears together in combinations
+func requireCovered(t *testing.T, args map[string][]string, strength int, combinations []map[string]string) {
+	keys := orderKeys(args, nil)
+	for _, subset := range subsets(len(keys), strength) {
+		// Enumerate every tuple of values for the subset of keys
+		tuples := [][]string{{}}
+		for _, x := range subset {
+			var next [][]string
+			for _, tuple := range tuples {
+				for _, val := range args[keys[x]] {
+					next = append(next, append(append([]string{}, tuple...), val))
+				}
+			}
+			tuples = next
+		}
+
+		for _, tuple := range tuples {
+			found := false
+			for _, combination := range combinations {
+				matches := true
+				for i, x := range subset {
+					if combination[keys[x]] != tuple[i] {
+						matches = false
+						break
+					}
+				}
+				if matches {
+					found = true
+					break
+				}
+			}
+			require.True(t, found, "values %v of %v are never used together", tuple, subset)
+		}
+	}
+}
+
+func TestNWise(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		input    map[string][]string
+		strength int
+		max      int
+	}{
+		{
+			name:     "covers every value with a strength of one",
+			input:    strategyInput,
+			strength: 1,
+			max:      3,
+		},
+		{
+			name:     "covers every pair of values",
+			input:    strategyInput,
+			strength: 2,
+			max:      15,
+		},
+		{
+			name:     "covers every triple of values",
+			input:    strategyInput,
+			strength: 3,
+			max:      60,
+		},
+		{
+			name:     "covers every combination when the strength exceeds the number of parameters",
+			input:    testdata.CombinationInput,
+			strength: 4,
+			max:      8,
+		},
+	} {
+		for _, solveAhead := range []bool{false, true} {
+			t.Run(tt.name, func(t *testing.T) {
+				combinationStream := NewStream(
+					WithArgs(tt.input),
+					WithStrategy(NWise(tt.strength)),
+					WithSolveAhead(solveAhead),
+				)
+
+				var got []map[string]string
+
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+
+				for {
+					next, err := combinationStream.Next(ctx)
+					require.NoError(t, err, "error received while processing combination stream")
+
+					if next == nil {
+						break
+					}
+
+					got = append(got, next)
+				}
+
+				require.Equal(t, combinationStream.Len(), len(got))
+				require.LessOrEqual(t, len(got), tt.max, "too many combinations generated")
+				requireCovered(t, tt.input, tt.strength, got)
+
+				// The same args always result in the same combinations
+				again := NewStream(WithArgs(tt.input), WithStrategy(NWise(tt.strength)))
+				for i, combination := range got {
+					expected, err := again.At(i)
+					require.NoError(t, err)
+					require.Equal(t, expected, combination, "combination %d is not deterministic", i)
+				}
+			})
+		}
+	}
+
+	_, err := NewStream(WithArgs(strategyInput), WithStrategy(NWise(0))).Next(context.Background())
+	require.ErrorIs(t, err, ErrInvalidStrategy)
+
+	_, err = NewStream(WithArgs(strategyInput), WithStrategy(NWise(MaxStrength+1))).Next(context.Background())
+	require.ErrorIs(t, err, ErrInvalidStrategy)
+}
+
+func TestNWiseBounds(t *testing.T) {
+	// The strength spans every parameter, so every combination is covered in the order of the product
+	product := NewStream(WithArgs(testdata.CombinationInput), WithStrategy(Product))
+	nwise := NewStream(WithArgs(testdata.CombinationInput), WithStrategy(NWise(MaxStrength)))
+	require.Equal(t, product.Len(), nwise.Len())
+	for i := 0; i < product.Len(); i++ {
+		expected, err := product.At(i)
+		require.NoError(t, err)
+		combination, err := nwise.At(i)
+		require.NoError(t, err)
+		require.Equal(t, expected, combination)
+	}
+
+	// Too many tuples to track are rejected before any of them are allocated
+	args := map[string][]string{}
+	for i := 0; i < 40; i++ {
+		args[fmt.Sprintf("KEY_%02d", i)] = []string{"a", "b", "c"}
+	}
+	_, err := NewStream(WithArgs(args), WithStrategy(NWise(MaxStrength))).Next(context.Background())
+	require.ErrorIs(t, err, ErrTooManyCombinations)
+}
+
+func TestNWiseExclusions(t *testing.T) {
+	args := map[string][]string{"A": {"a1", "a2"}, "B": {"b1", "b2"}, "C": {"c1", "c2"}}
+	all := NewStream(WithArgs(args), WithStrategy(Pairwise))
+	excluded, err := all.At(0)
+	require.NoError(t, err)
+
+	// Excluded combinations are skipped rather than replaced, so their pairs may no longer be covered
+	exclusion := map[string]string{"A": excluded["A"], "B": excluded["B"]}
+	combinationStream := NewStream(WithArgs(args), WithStrategy(Pairwise), WithExclusions([]map[string]string{exclusion}))
+	require.Equal(t, all.Len(), combinationStream.Len())
+
+	var got []map[string]string
+	for {
+		next, err := combinationStream.Next(context.Background())
+		require.NoError(t, err)
+		if next == nil {
+			break
+		}
+		got = append(got, next)
+	}
+	require.Len(t, got, all.Len()-1)
+	for _, combination := range got {
+		require.False(t, combination["A"] == exclusion["A"] && combination["B"] == exclusion["B"])
+	}
+}
+
+func TestParseStrategy(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		strategy string
+		strength int
+		expected Strategy
+		err      error
+	}{
+		{
+			name:     "defaults to product",
+			expected: Product,
+		},
+		{
+			name:     "parses product",
+			strategy: "Product",
+			expected: Product,
+		},
+		{
+			name:     "parses pairwise",
+			strategy: "pairwise",
+			expected: Pairwise,
+		},
+		{
+			name:     "parses nwise",
+			strategy: "NWise",
+			strength: 3,
+			expected: NWise(3),
+		},
+		{
+			name:     "rejects nwise without a strength",
+			strategy: "nwise",
+			err:      ErrInvalidStrategy,
+		},
+		{
+			name:     "rejects nwise with too high a strength",
+			strategy: "nwise",
+			strength: MaxStrength + 1,
+			err:      ErrInvalidStrategy,
+		},
+		{
+			name:     "rejects an unknown strategy",
+			strategy: "random",
+			err:      ErrInvalidStrategy,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			strategy, err := ParseStrategy(tt.strategy, tt.strength)
+			require.ErrorIs(t, err, tt.err)
+			require.Equal(t, tt.expected, strategy, fmt.Sprintf("%q parsed incorrectly", tt.strategy))
+		})
+	}
+}
@@ -2,46 +2,197 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/operator-framework/combo/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
+	"github.com/operator-framework/combo/pkg/applier"
 	combinationPkg "github.com/operator-framework/combo/pkg/combination"
+	"github.com/operator-framework/combo/pkg/overflow"
+	"github.com/operator-framework/combo/pkg/parameter"
 	templatePkg "github.com/operator-framework/combo/pkg/template"
 	"github.com/operator-framework/combo/pkg/updater"
+	"github.com/operator-framework/combo/pkg/values"
 )
 
 const (
 	ReferencedTemplateLabel = "combo.ReferencedTemplate"
 )
 
+// progressDelay is how long evaluating and applying a combination takes before it's reported as in progress
+const progressDelay = 5 * time.Second
+
+// redacted replaces the values of arguments sourced from Secrets wherever they would be recorded in the status
+const redacted = "<redacted>"
+
 type combinationController struct {
 	client.Client
-	log logr.Logger
+	log        logr.Logger
+	scope      scope
+	options    options
+	controller controller.Controller
+	recorder   record.EventRecorder
+
+	// config is the configuration the clients impersonating ServiceAccounts are derived from
+	config *rest.Config
+
+	// watching holds the kinds of objects arguments select their values from that are already watched,
+	// and impersonating holds the clients acting as each ServiceAccount resources are managed as
+	watching      map[schema.GroupVersionKind]struct{}
+	impersonating map[string]client.Client
+	mu            sync.Mutex
 }
 
 // manageWith creates a new instance of this controller
 func (c *combinationController) manageWith(mgr ctrl.Manager, verbosity int) error {
 	c.log = c.log.V(verbosity)
 	templateHandler := handler.EnqueueRequestsFromMapFunc(c.mapTemplateToCombinations)
+	configMapHandler := handler.EnqueueRequestsFromMapFunc(c.mapSourceToCombinations(func(source *v1alpha1.ValuesSource) *v1alpha1.KeyReference {
+		return source.ConfigMapKeyRef
+	}))
+	secretHandler := handler.EnqueueRequestsFromMapFunc(c.mapSourceToCombinations(func(source *v1alpha1.ValuesSource) *v1alpha1.KeyReference {
+		return source.SecretKeyRef
+	}))
+
+	ctl, err := ctrl.NewControllerManagedBy(mgr).
+		For(c.scope.newCombination(), builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{}))).
+		Watches(&source.Kind{Type: c.scope.newTemplate()}, templateHandler, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, configMapHandler).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, secretHandler).
+		Build(c)
+	if err != nil {
+		return err
+	}
+
+	c.controller = ctl
+	c.recorder = mgr.GetEventRecorderFor("combo")
+	c.config = mgr.GetConfig()
+	c.watching = map[schema.GroupVersionKind]struct{}{}
+	c.impersonating = map[string]client.Client{}
+	return nil
+}
+
+// watch starts watching objects of the given kind, which arguments select their values from, unless they
+// are already watched. Since any kind can be selected, watches are only added once a combination selects it.
+func (c *combinationController) watch(gvk schema.GroupVersionKind) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.watching[gvk]; ok {
+		return nil
+	}
+
+	object := &unstructured.Unstructured{}
+	object.SetGroupVersionKind(gvk)
+	if err := c.controller.Watch(&source.Kind{Type: object}, handler.EnqueueRequestsFromMapFunc(c.mapSelectedToCombinations)); err != nil {
+		return fmt.Errorf("failed to watch %s: %w", gvk.String(), err)
+	}
+
+	c.log.Info(fmt.Sprintf("watching %s for combinations selecting their arguments from it", gvk.String()))
+	c.watching[gvk] = struct{}{}
+	return nil
+}
+
+// mapSelectedToCombinations finds all of the combinations with an argument selecting the given object,
+// which should be requeued whenever an object they select, or used to select, changes
+func (c *combinationController) mapSelectedToCombinations(object client.Object) []reconcile.Request {
+	if object == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	requests := []reconcile.Request{}
+
+	// Find all of the combinations that select this object
+	combinations, err := c.listCombinations(ctx, object.GetNamespace())
+	if err != nil {
+		return requests
+	}
+
+	//  Enqueue reliant combinations for updates
+	for _, combination := range combinations {
+		arguments, err := c.arguments(combination)
+		if err != nil {
+			continue
+		}
+		if values.Selects(arguments, object) {
+			c.log.Info(fmt.Sprintf("enqueueing %s combination in response to the selected %s %s being updated", combination.GetName(), object.GetObjectKind().GroupVersionKind().Kind, client.ObjectKeyFromObject(object)))
+			requests = append(requests, reconcile.Request{
+				NamespacedName: client.ObjectKeyFromObject(combination),
+			})
+		}
+	}
+
+	return requests
+}
+
+// mapSourceToCombinations returns a function finding all of the combinations with an argument that sources
+// its values from a given object, which should be requeued whenever the object changes. The reference of
+// each argument's source to compare with the object is selected by ref.
+func (c *combinationController) mapSourceToCombinations(ref func(*v1alpha1.ValuesSource) *v1alpha1.KeyReference) handler.MapFunc {
+	return func(object client.Object) []reconcile.Request {
+		if object == nil {
+			return nil
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
 
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&v1alpha1.Combination{}).
-		Watches(&source.Kind{Type: &v1alpha1.Template{}}, templateHandler).
-		Complete(c)
+		requests := []reconcile.Request{}
+
+		// Find all of the combinations that rely on this object
+		combinations, err := c.listCombinations(ctx, object.GetNamespace())
+		if err != nil {
+			return requests
+		}
+
+		//  Enqueue reliant combinations for updates
+		for _, combination := range combinations {
+			arguments, err := c.arguments(combination)
+			if err != nil {
+				continue
+			}
+			if values.References(arguments, object.GetNamespace(), object.GetName(), ref) {
+				c.log.Info(fmt.Sprintf("enqueueing %s combination in response to the %s/%s source of its arguments being updated", combination.GetName(), object.GetNamespace(), object.GetName()))
+				requests = append(requests, reconcile.Request{
+					NamespacedName: client.ObjectKeyFromObject(combination),
+				})
+			}
+		}
+
+		return requests
+	}
 }
 
 // mapTemplateToCombinations is responsible for taking the template object and finding all associated
 // combinations that should be requeued. This should only happen whenever a template is changed in someway.
+// Requeued combinations are re-evaluated, which also prunes any resources the updated template no longer produces.
+// Suspended combinations are left out, since they're not evaluated until they're resumed anyway.
 func (c *combinationController) mapTemplateToCombinations(template client.Object) []reconcile.Request {
 	if template == nil {
 		return nil
@@ -56,17 +207,20 @@ func (c *combinationController) mapTemplateToCombinations(template client.Object
 	templateName := template.GetName()
 
 	// Find all of the combinations that rely on this template
-	combinationList := v1alpha1.CombinationList{}
-	if err := c.List(ctx, &combinationList, &client.ListOptions{}); err != nil {
+	combinations, err := c.listCombinations(ctx, template.GetNamespace())
+	if err != nil {
 		return requests
 	}
 
 	//  Enqueue reliant combinations for updates
-	for _, combination := range combinationList.Items {
-		if combination.Spec.Template == templateName {
-			c.log.Info(fmt.Sprintf("enqueueing %s combination in response to associated %s template being updated", combination.Name, templateName))
+	for _, combination := range combinations {
+		if combination.GetSpec().Suspend {
+			continue
+		}
+		if combination.GetSpec().Template == templateName {
+			c.log.Info(fmt.Sprintf("enqueueing %s combination in response to associated %s template being updated", combination.GetName(), templateName))
 			requests = append(requests, reconcile.Request{
-				NamespacedName: types.NamespacedName{Name: combination.Name},
+				NamespacedName: client.ObjectKeyFromObject(combination),
 			})
 		}
 	}
@@ -82,89 +236,621 @@ func (c *combinationController) Reconcile(ctx context.Context, req ctrl.Request)
 	log.Info("new combination event inbound")
 
 	// Attempt to retrieve the requested combination CR
-	combination := &v1alpha1.Combination{}
+	combination := c.scope.newCombination()
 	err := c.Get(ctx, req.NamespacedName, combination)
 	if err != nil {
 		return reconcile.Result{}, client.IgnoreNotFound(err)
 	}
+	spec := combination.GetSpec()
 
-	// If combination is being deleted, remove from queue
-	if !combination.ObjectMeta.DeletionTimestamp.IsZero() {
-		log.Info("combination is being deleted, ignoring event")
-		return reconcile.Result{}, nil
+	// If combination is being deleted, clean up its resources before letting it go
+	if !combination.GetDeletionTimestamp().IsZero() {
+		log.Info("combination is being deleted, cleaning up its resources")
+		return reconcile.Result{}, c.finalize(ctx, combination)
 	}
 
-	// create update client and defer updates until exiting the control loop
+	// Ensure the combination cannot be deleted before its resources are cleaned up
+	if !controllerutil.ContainsFinalizer(combination, v1alpha1.CleanupFinalizer) {
+		controllerutil.AddFinalizer(combination, v1alpha1.CleanupFinalizer)
+		if err := c.Update(ctx, combination); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
+	// create update client and defer updates until exiting the control loop, at which point the
+	// status reflects the current generation and no evaluation is in progress anymore
+	generation := combination.GetGeneration()
 	u := updater.New(c.Client)
+	u.UpdateStatus(updater.EnsureObservedGeneration(generation))
 	defer func() {
+		u.UpdateStatus(updater.EnsureCondition(metav1.Condition{
+			Type:               v1alpha1.TypeInProgress,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: generation,
+			Reason:             v1alpha1.ReasonProcessed,
+			Message:            "no evaluation in progress",
+		}))
 		if err := u.Apply(ctx, combination); err != nil {
 			log.Error(err, "failed to update status")
 		}
 	}()
 
+	// Leave the resources of a suspended combination as they are until it's resumed, only planning
+	// them when a one-off plan is requested, which never changes them either
+	if spec.Suspend {
+		u.UpdateStatus(updater.EnsureCondition(metav1.Condition{
+			Type:               v1alpha1.TypeSuspended,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: generation,
+			Reason:             v1alpha1.ReasonSuspended,
+			Message:            "evaluation and application of resources is suspended",
+		}))
+		if !planRequested(combination) {
+			log.Info("combination is suspended, skipping evaluation")
+			return reconcile.Result{}, nil
+		}
+	} else {
+		u.UpdateStatus(updater.RemoveCondition(v1alpha1.TypeSuspended))
+	}
+
 	// Remove any previous evaluation in case of failure
-	combination.Status.Evaluations = []string{}
+	combination.GetStatus().Evaluations = []string{}
+
+	// Attempt to retrieve the template referenced in the combination CR, which for namespaced combinations is within their namespace
+	template := c.scope.newTemplate()
+	if err := c.Get(ctx, types.NamespacedName{Namespace: combination.GetNamespace(), Name: spec.Template}, template); err != nil {
+		u.UpdateStatus(updater.EnsureCondition(metav1.Condition{
+			Type:               v1alpha1.TypeInvalid,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: generation,
+			Reason:             v1alpha1.ReasonTemplateNotFound,
+			Message:            fmt.Sprintf("failed to retrieve %s template: %s", spec.Template, err.Error()),
+		}))
+		return reconcile.Result{}, err
+	}
+
+	strategy, err := combinationPkg.ParseStrategy(spec.Strategy, spec.Strength)
+	if err != nil {
+		u.UpdateStatus(updater.EnsureCondition(metav1.Condition{
+			Type:               v1alpha1.TypeInvalid,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: generation,
+			Reason:             v1alpha1.ReasonEvaluationsInvalid,
+			Message:            fmt.Sprintf("failed to determine which combinations to evaluate: %s", err.Error()),
+		}))
+		return reconcile.Result{}, err
+	}
+
+	// Read the values of every argument sourced from another object, as long as the kinds of objects they select may be watched
+	var args map[string][]string
+	arguments, err := c.arguments(combination)
+	if err == nil {
+		err = values.Restrict(arguments, c.options.selectableKinds)
+	}
+	if err == nil {
+		args, err = values.Resolve(ctx, c.Client, arguments)
+	}
+	if err != nil {
+		u.UpdateStatus(updater.EnsureCondition(metav1.Condition{
+			Type:               v1alpha1.TypeInvalid,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: generation,
+			Reason:             v1alpha1.ReasonArgumentsUnresolved,
+			Message:            fmt.Sprintf("failed to resolve the values of the arguments: %s", err.Error()),
+		}))
+		return reconcile.Result{}, err
+	}
+
+	// Values sourced from Secrets must not be revealed by the status, which is less protected than the Secrets
+	sensitiveKeys := values.Sensitive(arguments)
+	secretValues := sensitiveValues(args, sensitiveKeys)
+
+	// Requeue the combination whenever the objects its arguments select change
+	for _, gvk := range values.Kinds(arguments) {
+		if err := c.watch(gvk); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
 
-	// Attempt to retrieve the template referenced in the combination CR
-	template := &v1alpha1.Template{}
-	if err := c.Get(ctx, types.NamespacedName{Name: combination.Spec.Template}, template); err != nil {
+	// Check the arguments against the template's parameters, filling in any defaults
+	if err := parameter.Match(template.GetSpec().Parameters, args); err != nil {
 		u.UpdateStatus(updater.EnsureCondition(metav1.Condition{
-			Type:    v1alpha1.TypeInvalid,
-			Status:  metav1.ConditionTrue,
-			Reason:  v1alpha1.ReasonTemplateNotFound,
-			Message: fmt.Sprintf("failed to retrieve %s template: %s", combination.Spec.Template, err.Error()),
+			Type:               v1alpha1.TypeInvalid,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: generation,
+			Reason:             v1alpha1.ReasonArgumentsMismatch,
+			Message:            fmt.Sprintf("arguments do not match the parameters of %s template: %s", spec.Template, err.Error()),
+		}))
+		return reconcile.Result{}, err
+	}
+
+	args, err = parameter.Resolve(template.GetSpec().Parameters, args)
+	if err != nil {
+		u.UpdateStatus(updater.EnsureCondition(metav1.Condition{
+			Type:               v1alpha1.TypeInvalid,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: generation,
+			Reason:             v1alpha1.ReasonArgumentsInvalid,
+			Message:            redact(fmt.Sprintf("arguments do not satisfy the parameters of %s template: %s", spec.Template, err.Error()), secretValues),
 		}))
 		return reconcile.Result{}, err
 	}
 
 	// Build combination stream to be utilized in template builder
 	comboStream := combinationPkg.NewStream(
-		combinationPkg.WithArgs(formatArguments(combination.Spec.Arguments)),
+		combinationPkg.WithArgs(args),
+		combinationPkg.WithParameterOrder(argumentKeys(spec.Arguments)),
+		combinationPkg.WithZip(spec.Zip...),
+		combinationPkg.WithExclusions(spec.Exclude),
+		combinationPkg.WithStrategy(strategy),
 		combinationPkg.WithSolveAhead(),
 	)
 
 	// Create a new template builder
-	builder, err := templatePkg.NewBuilder(strings.NewReader(template.Spec.Body), comboStream)
+	options, err := templatePkg.OptionsFor(*template.GetSpec())
+	if err != nil {
+		u.UpdateStatus(updater.EnsureCondition(metav1.Condition{
+			Type:               v1alpha1.TypeInvalid,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: generation,
+			Reason:             v1alpha1.ReasonTemplateBodyInvalid,
+			Message:            fmt.Sprintf("failed to determine how to evaluate %s template: %s", spec.Template, err.Error()),
+		}))
+		return reconcile.Result{}, err
+	}
+
+	counter := &countingStream{CombinationStream: comboStream}
+	builder, err := templatePkg.NewBuilder(strings.NewReader(template.GetSpec().Body), counter, options...)
 	if err != nil {
 		u.UpdateStatus(updater.EnsureCondition(metav1.Condition{
-			Type:    v1alpha1.TypeInvalid,
-			Status:  metav1.ConditionTrue,
-			Reason:  v1alpha1.ReasonTemplateBodyInvalid,
-			Message: fmt.Sprintf("failed to construct a builder out of %s template body:  %s", combination.Spec.Template, err.Error()),
+			Type:               v1alpha1.TypeInvalid,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: generation,
+			Reason:             v1alpha1.ReasonTemplateBodyInvalid,
+			Message:            fmt.Sprintf("failed to construct a builder out of %s template body:  %s", spec.Template, err.Error()),
 		}))
 		return reconcile.Result{}, err
 	}
 
-	// Build the manifest combinations
-	generatedManifests, err := builder.Build(ctx)
+	// Report that the combinations are being evaluated once that takes a while, as it can for large combinations,
+	// rather than flipping the InProgress condition back and forth on every reconciliation. The report is over
+	// before the status is updated on the way out, since this defer runs first.
+	stopProgress := c.reportProgress(ctx, combination, metav1.Condition{
+		Type:               v1alpha1.TypeInProgress,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: generation,
+		Reason:             v1alpha1.ReasonProcessing,
+		Message:            fmt.Sprintf("evaluating up to %d combinations of %s template", comboStream.Len(), spec.Template),
+	})
+	defer stopProgress()
+
+	// Build the manifest combinations, recording what produced each of them
+	generatedEvaluations, err := builder.Evaluate(ctx)
 	if err != nil {
 		u.UpdateStatus(updater.EnsureCondition(metav1.Condition{
-			Type:    v1alpha1.TypeInvalid,
-			Status:  metav1.ConditionTrue,
-			Reason:  v1alpha1.ReasonEvaluationsInvalid,
-			Message: fmt.Sprintf("failed to generate manifest %s combinations: %s", combination.Spec.Template, err.Error()),
+			Type:               v1alpha1.TypeInvalid,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: generation,
+			Reason:             v1alpha1.ReasonEvaluationsInvalid,
+			Message:            redact(fmt.Sprintf("failed to generate manifest %s combinations: %s", spec.Template, err.Error()), secretValues),
+		}))
+		return reconcile.Result{}, err
+	}
+	var generatedManifests []string
+	for _, evaluation := range generatedEvaluations {
+		generatedManifests = append(generatedManifests, evaluation.Manifest)
+	}
+
+	// Only determine what applying the evaluations would change while the combination is planned, leaving
+	// the cluster and the rest of the status, which describes the resources last applied, untouched
+	a, err := c.applierFor(combination)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if planned(combination) {
+		plan, err := a.Plan(ctx, generatedManifests, combination.GetStatus().Resources)
+		if plan != nil {
+			plan.ObservedGeneration = generation
+			redactResources(plan.Failures, secretValues)
+		}
+		u.UpdateStatus(updater.EnsurePlan(plan), updater.EnsureCondition(plannedCondition(plan, err, generation)))
+		if err != nil || !planRequested(combination) {
+			return reconcile.Result{}, err
+		}
+
+		// A one-off plan is only computed once, so record it right away and remove the annotation requesting it
+		if err := u.Apply(ctx, combination); err != nil {
+			return reconcile.Result{}, err
+		}
+		return reconcile.Result{}, c.removePlanAnnotation(ctx, combination)
+	}
+	// A one-off plan stays in the status once its generation is applied, until the combination changes again
+	if plan := combination.GetStatus().Plan; plan == nil || plan.ObservedGeneration != generation {
+		u.UpdateStatus(updater.EnsurePlan(nil), updater.RemoveCondition(v1alpha1.TypePlanned))
+	}
+
+	// Record the evaluations in the status, or in ConfigMaps once they're too large for it
+	evaluations, overflow, err := c.store(ctx, combination, generatedManifests, len(sensitiveKeys) != 0)
+	if err != nil {
+		u.UpdateStatus(updater.EnsureCondition(metav1.Condition{
+			Type:               v1alpha1.TypeFinished,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: generation,
+			Reason:             v1alpha1.ReasonOverflowFailed,
+			Message:            err.Error(),
 		}))
 		return reconcile.Result{}, err
 	}
 
 	// Add the combination evaluations and update Status
 	u.UpdateStatus(updater.EnsureCondition(metav1.Condition{
-		Type:    v1alpha1.TypeFinished,
-		Status:  metav1.ConditionTrue,
-		Reason:  v1alpha1.ReasonProcessed,
-		Message: "evaluations successfully processed",
-	}), updater.EnsureEvaluations(generatedManifests))
+		Type:               v1alpha1.TypeFinished,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: generation,
+		Reason:             v1alpha1.ReasonProcessed,
+		Message:            "evaluations successfully processed",
+	}), updater.EnsureEvaluations(evaluations), updater.EnsureEvaluationsOverflow(overflow))
+
+	// Apply the evaluations to the cluster, then prune anything from the previous inventory
+	// that is no longer part of them and record the outcome of each
+	resources, applyErr := a.Apply(ctx, generatedManifests)
+	redactResources(resources, secretValues)
+	remaining, pruneErr := a.Prune(ctx, combination.GetStatus().Resources, resources)
+	// The records grow along with the evaluations, so they're left out of the status as well once the evaluations are too large for it
+	var records []v1alpha1.EvaluationRecord
+	if !c.oversized(generatedManifests) {
+		records = evaluationRecords(generatedEvaluations, resources, sensitiveKeys, secretValues)
+	}
+	u.UpdateStatus(updater.EnsureEvaluationRecords(records))
+	u.UpdateStatus(updater.EnsureCounts(countsFor(counter.count, resources)))
+	resources = append(resources, remaining...)
+	applied := appliedCondition(resources)
+	applied.ObservedGeneration = generation
+	u.UpdateStatus(updater.EnsureResources(resources), updater.EnsureCondition(applied))
 
 	// Return and update the combination's status
-	return reconcile.Result{}, err
+	return reconcile.Result{}, utilerrors.NewAggregate([]error{applyErr, pruneErr})
+}
+
+// reportProgress sets the condition on the combination's status once progressDelay passes, unless the returned function
+// is called first. The returned function waits for a report that's already underway to be applied.
+func (c *combinationController) reportProgress(ctx context.Context, combination v1alpha1.CombinationObject, condition metav1.Condition) func() {
+	progress := combination.DeepCopyObject().(v1alpha1.CombinationObject)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		timer := time.NewTimer(progressDelay)
+		defer timer.Stop()
+		select {
+		case <-stop:
+			return
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		u := updater.New(c.Client)
+		u.UpdateStatus(updater.EnsureCondition(condition))
+		if err := u.Apply(ctx, progress); err != nil {
+			c.log.Error(err, "failed to report progress", "combination", client.ObjectKeyFromObject(progress))
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+	}
+}
+
+// finalize deletes the resources generated by the combination, unless its deletion policy is to
+// orphan them, and then removes the cleanup finalizer so the combination can be released
+func (c *combinationController) finalize(ctx context.Context, combination v1alpha1.CombinationObject) error {
+	if !controllerutil.ContainsFinalizer(combination, v1alpha1.CleanupFinalizer) {
+		return nil
+	}
+
+	if combination.GetSpec().DeletionPolicy != v1alpha1.DeletionPolicyOrphan {
+		a, err := c.applierFor(combination)
+		if err != nil {
+			return err
+		}
+		if _, err := a.Prune(ctx, combination.GetStatus().Resources, nil); err != nil {
+			if !errors.Is(err, applier.ErrPruneForbidden) {
+				return err
+			}
+			// The ServiceAccount the resources are managed as may have lost access to them, e.g. once it's deleted along
+			// with its namespace, which would block the deletion of the combination forever, so orphan them instead
+			c.log.Info("orphaning resources that are forbidden to prune", "combination", combination.GetName(), "error", err.Error())
+			c.recorder.Event(combination, corev1.EventTypeWarning, "PruneForbidden", fmt.Sprintf("Orphaned its resources: %s", err.Error()))
+		}
+	}
+
+	controllerutil.RemoveFinalizer(combination, v1alpha1.CleanupFinalizer)
+	return c.Update(ctx, combination)
+}
+
+// store returns the evaluations to record in the combination's status. Once they're larger than the maximum size
+// of the status' evaluations, they're stored in ConfigMaps instead and only the reference to them is returned.
+// Sensitive evaluations, which hold values sourced from Secrets, are always stored in Secrets instead, or not at
+// all without a namespace to store them in. Stored evaluations the combination no longer has are pruned either way.
+func (c *combinationController) store(ctx context.Context, combination v1alpha1.CombinationObject, evaluations []string, sensitive bool) ([]string, *v1alpha1.EvaluationsOverflow, error) {
+	namespace := combination.GetNamespace()
+	if !c.scope.namespaced {
+		namespace = c.options.evaluationsNamespace
+	}
+	var options []overflow.StoreOption
+	if sensitive {
+		options = append(options, overflow.WithSecrets())
+	}
+	store := overflow.New(c.Client, combination, namespace, options...)
+	previous := combination.GetStatus().EvaluationsOverflow
+
+	if sensitive && namespace == "" {
+		return nil, nil, store.Prune(ctx, previous, nil)
+	}
+	if !sensitive && !c.oversized(evaluations) {
+		return evaluations, nil, store.Prune(ctx, previous, nil)
+	}
+
+	current, err := store.Write(ctx, evaluations)
+	if err != nil {
+		return nil, nil, err
+	}
+	return nil, current, store.Prune(ctx, previous, current)
+}
+
+// oversized determines whether the evaluations are larger than the maximum size of the status' evaluations
+func (c *combinationController) oversized(evaluations []string) bool {
+	return c.options.maxStatusEvaluationsSize > 0 && overflow.Size(evaluations) > c.options.maxStatusEvaluationsSize
+}
+
+// listCombinations lists the combinations that may refer to objects within the given namespace, which
+// are every combination unless they're namespaced
+func (c *combinationController) listCombinations(ctx context.Context, namespace string) ([]v1alpha1.CombinationObject, error) {
+	var options []client.ListOption
+	if c.scope.namespaced {
+		options = append(options, client.InNamespace(namespace))
+	}
+
+	combinationList := c.scope.newCombinationList()
+	if err := c.List(ctx, combinationList, options...); err != nil {
+		return nil, err
+	}
+	return combinationList.GetCombinations(), nil
+}
+
+// arguments returns the combination's arguments, confining their sources to the combination's namespace if it's namespaced
+func (c *combinationController) arguments(combination v1alpha1.CombinationObject) ([]v1alpha1.Argument, error) {
+	if !c.scope.namespaced {
+		return combination.GetSpec().Arguments, nil
+	}
+	return values.Confine(combination.GetSpec().Arguments, combination.GetNamespace())
+}
+
+// applierFor returns an applier of the combination's resources. Those of a namespaced combination are confined to its
+// namespace and managed as the ServiceAccount named by its spec, so it can't manage anything the ServiceAccount can't.
+func (c *combinationController) applierFor(combination v1alpha1.CombinationObject) (applier.Applier, error) {
+	if !c.scope.namespaced {
+		cli, err := c.clientFor(c.options.serviceAccount)
+		if err != nil {
+			return applier.Applier{}, err
+		}
+		return applier.New(cli, combination.GetName()), nil
+	}
+
+	serviceAccount := types.NamespacedName{Namespace: combination.GetNamespace(), Name: combination.GetSpec().ServiceAccountName}
+	if serviceAccount.Name == "" {
+		serviceAccount.Name = "default"
+	}
+	cli, err := c.clientFor(serviceAccount)
+	if err != nil {
+		return applier.Applier{}, err
+	}
+	return applier.New(cli, combination.GetName(), applier.WithNamespace(combination.GetNamespace(), c.RESTMapper())), nil
+}
+
+// clientFor returns a client impersonating the given ServiceAccount, or the controller's own client if it's unset.
+// The client of each ServiceAccount is created once and reused afterwards.
+func (c *combinationController) clientFor(serviceAccount types.NamespacedName) (client.Client, error) {
+	if serviceAccount.Name == "" {
+		return c.Client, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	username := fmt.Sprintf("system:serviceaccount:%s:%s", serviceAccount.Namespace, serviceAccount.Name)
+	if cli, ok := c.impersonating[username]; ok {
+		return cli, nil
+	}
+
+	config := rest.CopyConfig(c.config)
+	config.Impersonate = rest.ImpersonationConfig{UserName: username}
+	cli, err := client.New(config, client.Options{Scheme: c.Scheme(), Mapper: c.RESTMapper()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to impersonate %s: %w", username, err)
+	}
+	c.impersonating[username] = cli
+	return cli, nil
+}
+
+// appliedCondition summarizes the outcome of applying the combination's resources
+func appliedCondition(resources []v1alpha1.ResourceStatus) metav1.Condition {
+	var failed int
+	for _, resource := range resources {
+		if resource.Result == v1alpha1.ResultFailed {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return metav1.Condition{
+			Type:    v1alpha1.TypeApplied,
+			Status:  metav1.ConditionFalse,
+			Reason:  v1alpha1.ReasonApplyFailed,
+			Message: fmt.Sprintf("%d of %d resources failed to apply", failed, len(resources)),
+		}
+	}
+
+	return metav1.Condition{
+		Type:    v1alpha1.TypeApplied,
+		Status:  metav1.ConditionTrue,
+		Reason:  v1alpha1.ReasonApplied,
+		Message: fmt.Sprintf("%d resources successfully applied", len(resources)),
+	}
+}
+
+// planned determines whether the changes to the combination's resources should be planned instead of applied,
+// either because of its mode or because it's annotated for a one-off plan
+func planned(combination v1alpha1.CombinationObject) bool {
+	return combination.GetSpec().Mode == v1alpha1.ModePlan || planRequested(combination)
+}
+
+// planRequested determines whether the combination is annotated for a one-off plan
+func planRequested(combination v1alpha1.CombinationObject) bool {
+	return combination.GetAnnotations()[v1alpha1.PlanAnnotation] == "true"
+}
+
+// removePlanAnnotation removes the annotation requesting a one-off plan from the combination once the plan is recorded
+func (c *combinationController) removePlanAnnotation(ctx context.Context, combination v1alpha1.CombinationObject) error {
+	patch := client.MergeFrom(combination.DeepCopyObject().(client.Object))
+	annotations := combination.GetAnnotations()
+	delete(annotations, v1alpha1.PlanAnnotation)
+	combination.SetAnnotations(annotations)
+	return c.Patch(ctx, combination, patch)
+}
+
+// plannedCondition summarizes the plan of the changes to the combination's resources
+func plannedCondition(plan *v1alpha1.Plan, err error, generation int64) metav1.Condition {
+	if err != nil || len(plan.Failures) > 0 {
+		message := fmt.Sprintf("%d resources would fail to apply", len(plan.Failures))
+		if err != nil {
+			message = err.Error()
+		}
+		return metav1.Condition{
+			Type:               v1alpha1.TypePlanned,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: generation,
+			Reason:             v1alpha1.ReasonPlanFailed,
+			Message:            message,
+		}
+	}
+
+	return metav1.Condition{
+		Type:               v1alpha1.TypePlanned,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: generation,
+		Reason:             v1alpha1.ReasonPlanned,
+		Message:            fmt.Sprintf("%d to create, %d to update, %d to delete, %d unchanged", plan.Creates, plan.Updates, plan.Deletes, plan.Unchanged),
+	}
+}
+
+// evaluationRecords describes what produced each evaluation, referencing the resource it was applied as if it was
+// applied successfully. The resources must hold the outcome of applying each evaluation, in the same order. The
+// values of the arguments with sensitive keys are redacted.
+// evaluationRecords describes what produced each evaluation, redacting sensitive values from its arguments and from the
+// resources it was applied as. The resources of the status keep them, since they're needed to prune the objects.
+func evaluationRecords(evaluations []templatePkg.Evaluation, resources []v1alpha1.ResourceStatus, sensitive map[string]bool, sensitiveValues []string) []v1alpha1.EvaluationRecord {
+	var records []v1alpha1.EvaluationRecord
+	for i, evaluation := range evaluations {
+		record := v1alpha1.EvaluationRecord{
+			ManifestIndex: evaluation.Index,
+			Hash:          evaluation.Hash(),
+		}
+		if len(evaluation.Arguments) != 0 {
+			record.Arguments = make(map[string]string, len(evaluation.Arguments))
+			for key, value := range evaluation.Arguments {
+				if sensitive[key] {
+					value = redacted
+				}
+				record.Arguments[key] = value
+			}
+		}
+		if i < len(resources) && resources[i].Result == v1alpha1.ResultApplied {
+			record.Resource = &v1alpha1.ResourceReference{
+				APIVersion: resources[i].APIVersion,
+				Kind:       resources[i].Kind,
+				Namespace:  redact(resources[i].Namespace, sensitiveValues),
+				Name:       redact(resources[i].Name, sensitiveValues),
+			}
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// sensitiveValues returns the distinct values of the arguments with sensitive keys, longest first so that
+// redacting them never leaves part of a longer value behind
+func sensitiveValues(args map[string][]string, sensitive map[string]bool) []string {
+	found := map[string]struct{}{}
+	var result []string
+	for key := range sensitive {
+		for _, value := range args[key] {
+			if _, ok := found[value]; value == "" || ok {
+				continue
+			}
+			found[value] = struct{}{}
+			result = append(result, value)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if len(result[i]) != len(result[j]) {
+			return len(result[i]) > len(result[j])
+		}
+		return result[i] < result[j]
+	})
+	return result
+}
+
+// redact replaces every sensitive value within the message
+func redact(message string, sensitiveValues []string) string {
+	for _, value := range sensitiveValues {
+		message = strings.ReplaceAll(message, value, redacted)
+	}
+	return message
+}
+
+// redactResources replaces every sensitive value within the messages of the resources, which may quote the
+// manifests that were rejected
+func redactResources(resources []v1alpha1.ResourceStatus, sensitiveValues []string) {
+	for i := range resources {
+		resources[i].Message = redact(resources[i].Message, sensitiveValues)
+	}
+}
+
+// countsFor summarizes the evaluation of the given number of combinations, whose manifests were applied with the given outcomes
+func countsFor(combinations int, resources []v1alpha1.ResourceStatus) updater.Counts {
+	counts := updater.Counts{TotalCombinations: combinations, RenderedManifests: len(resources)}
+	for _, resource := range resources {
+		if resource.Result == v1alpha1.ResultApplied {
+			counts.AppliedManifests++
+		} else {
+			counts.FailedManifests++
+		}
+	}
+	return counts
+}
+
+// countingStream counts the combinations read from the stream it wraps
+type countingStream struct {
+	templatePkg.CombinationStream
+	count int
+}
+
+func (s *countingStream) Next(ctx context.Context) (map[string]string, error) {
+	combination, err := s.CombinationStream.Next(ctx)
+	if combination != nil {
+		s.count++
+	}
+	return combination, err
 }
 
-// formatArguments takes the arguments for the combination and formats them ito what the combination package
-// is expecting
-func formatArguments(arguments []v1alpha1.Argument) map[string][]string {
-	formattedArguments := map[string][]string{}
+// argumentKeys returns the keys of the combination's arguments in the order they were specified
+func argumentKeys(arguments []v1alpha1.Argument) []string {
+	keys := make([]string, 0, len(arguments))
 	for _, argument := range arguments {
-		formattedArguments[argument.Key] = argument.Values
+		keys = append(keys, argument.Key)
 	}
-	return formattedArguments
+	return keys
 }
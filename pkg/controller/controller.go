@@ -3,11 +3,14 @@ package controller
 import (
 	"github.com/go-logr/logr"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	kscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/operator-framework/combo/api/v1alpha1"
+	"github.com/operator-framework/combo/pkg/values"
 )
 
 const verbosity = 1
@@ -50,8 +53,56 @@ type Controller struct {
 	managed manageables
 }
 
+// options holds the settings shared by every combination controller
+type options struct {
+	evaluationsNamespace     string
+	maxStatusEvaluationsSize int
+	selectableKinds          []schema.GroupKind
+	serviceAccount           types.NamespacedName
+}
+
+type ControllerOption func(*options)
+
+// WithEvaluationsNamespace stores the evaluations of cluster-scoped combinations that are too large for their status
+// in ConfigMaps within the given namespace. The evaluations of namespaced combinations are stored in their own namespace.
+func WithEvaluationsNamespace(namespace string) ControllerOption {
+	return func(o *options) {
+		o.evaluationsNamespace = namespace
+	}
+}
+
+// WithMaxStatusEvaluationsSize stores the evaluations of a combination in ConfigMaps instead of its status once
+// they're larger than n bytes in total. They're always stored in the status if n isn't positive.
+func WithMaxStatusEvaluationsSize(n int) ControllerOption {
+	return func(o *options) {
+		o.maxStatusEvaluationsSize = n
+	}
+}
+
+// WithSelectableKinds only lets arguments select their values from objects of the given kinds, whose objects are
+// watched across the cluster once a combination selects them. It defaults to values.DefaultSelectableKinds.
+func WithSelectableKinds(kinds ...schema.GroupKind) ControllerOption {
+	return func(o *options) {
+		o.selectableKinds = kinds
+	}
+}
+
+// WithServiceAccount applies, plans and prunes the resources of cluster-scoped combinations as the given ServiceAccount
+// instead of the controller itself, which then only needs to be allowed to impersonate it. The resources of namespaced
+// combinations are always managed as the ServiceAccount named by their spec, within their namespace.
+func WithServiceAccount(namespace, name string) ControllerOption {
+	return func(o *options) {
+		o.serviceAccount = types.NamespacedName{Namespace: namespace, Name: name}
+	}
+}
+
 // NewReconciler constructs and returns a controller.
-func NewController(cli client.Client, log logr.Logger) (*Controller, error) {
+func NewController(cli client.Client, log logr.Logger, controllerOptions ...ControllerOption) (*Controller, error) {
+	o := options{selectableKinds: values.DefaultSelectableKinds}
+	for _, option := range controllerOptions {
+		option(&o)
+	}
+
 	return &Controller{
 		Client: cli,
 		log:    log,
@@ -59,10 +110,24 @@ func NewController(cli client.Client, log logr.Logger) (*Controller, error) {
 			&templateController{
 				Client: cli,
 				log:    log.WithValues("controller", "template"),
+				scope:  clusterScope,
 			},
 			&combinationController{
+				Client:  cli,
+				log:     log.WithValues("controller", "combination"),
+				scope:   clusterScope,
+				options: o,
+			},
+			&templateController{
 				Client: cli,
-				log:    log.WithValues("controller", "combination"),
+				log:    log.WithValues("controller", "namespacedtemplate"),
+				scope:  namespacedScope,
+			},
+			&combinationController{
+				Client:  cli,
+				log:     log.WithValues("controller", "namespacedcombination"),
+				scope:   namespacedScope,
+				options: o,
 			},
 		},
 	}, nil
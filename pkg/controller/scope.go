@@ -0,0 +1,30 @@
+package controller
+
+import (
+	"github.com/operator-framework/combo/api/v1alpha1"
+)
+
+// scope describes the kinds of templates and combinations a controller reconciles. Cluster-scoped
+// combinations evaluate cluster-scoped templates and may generate resources anywhere, while namespaced
+// combinations evaluate templates within their own namespace and are confined to it.
+type scope struct {
+	namespaced         bool
+	newTemplate        func() v1alpha1.TemplateObject
+	newCombination     func() v1alpha1.CombinationObject
+	newCombinationList func() v1alpha1.CombinationObjectList
+}
+
+var (
+	clusterScope = scope{
+		newTemplate:        func() v1alpha1.TemplateObject { return &v1alpha1.Template{} },
+		newCombination:     func() v1alpha1.CombinationObject { return &v1alpha1.Combination{} },
+		newCombinationList: func() v1alpha1.CombinationObjectList { return &v1alpha1.CombinationList{} },
+	}
+
+	namespacedScope = scope{
+		namespaced:         true,
+		newTemplate:        func() v1alpha1.TemplateObject { return &v1alpha1.NamespacedTemplate{} },
+		newCombination:     func() v1alpha1.CombinationObject { return &v1alpha1.NamespacedCombination{} },
+		newCombinationList: func() v1alpha1.CombinationObjectList { return &v1alpha1.NamespacedCombinationList{} },
+	}
+)
@@ -2,30 +2,142 @@ package controller
 
 import (
 	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
 
 	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"github.com/operator-framework/combo/api/v1alpha1"
+	"github.com/operator-framework/combo/pkg/parameter"
+	templatePkg "github.com/operator-framework/combo/pkg/template"
 )
 
 type templateController struct {
 	client.Client
-	log logr.Logger
+	log   logr.Logger
+	scope scope
 }
 
+// manageWith creates a new instance of this controller
 func (t *templateController) manageWith(mgr ctrl.Manager, version int) error {
 	t.log = t.log.V(version)
+	combinationHandler := handler.EnqueueRequestsFromMapFunc(t.mapCombinationToTemplate)
+
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&v1alpha1.Template{}).
+		For(t.scope.newTemplate(), builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&source.Kind{Type: t.scope.newCombination()}, combinationHandler, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
 		Complete(t)
 }
 
+// mapCombinationToTemplate requeues the template a combination evaluates, so the template's
+// list of combinations stays up to date as combinations are created, changed and deleted.
+func (t *templateController) mapCombinationToTemplate(combination client.Object) []reconcile.Request {
+	c, ok := combination.(v1alpha1.CombinationObject)
+	if !ok || c.GetSpec().Template == "" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: c.GetNamespace(), Name: c.GetSpec().Template}}}
+}
+
+// Reconcile validates the template's parameters and body and records the outcome in its status
 func (t *templateController) Reconcile(ctx context.Context, req ctrl.Request) (reconcile.Result, error) {
 	// Set up a convenient log object so we don't have to type request over and over again
 	log := t.log.WithValues("request", req)
 	log.V(1).Info("reconciling template")
-	return reconcile.Result{}, nil
+
+	template := t.scope.newTemplate()
+	if err := t.Get(ctx, req.NamespacedName, template); err != nil {
+		return reconcile.Result{}, client.IgnoreNotFound(err)
+	}
+
+	// Find every combination that evaluates the template, which for namespaced templates are within its namespace
+	combinationList := t.scope.newCombinationList()
+	if err := t.List(ctx, combinationList, client.InNamespace(template.GetNamespace())); err != nil {
+		return reconcile.Result{}, err
+	}
+	var combinations []string
+	for _, combination := range combinationList.GetCombinations() {
+		if combination.GetSpec().Template == template.GetName() {
+			combinations = append(combinations, combination.GetName())
+		}
+	}
+	sort.Strings(combinations)
+
+	var declared []string
+	for _, p := range template.GetSpec().Parameters {
+		declared = append(declared, p.Name)
+	}
+
+	status := template.GetStatus().DeepCopy()
+	status.DeclaredParameters = declared
+	status.Combinations = combinations
+	status.DiscoveredParameters = nil
+	status.Manifests = 0
+	meta.SetStatusCondition(&status.Conditions, t.validate(template, status))
+
+	if reflect.DeepEqual(template.GetStatus(), status) {
+		return reconcile.Result{}, nil
+	}
+
+	log.Info("applying status changes")
+	*template.GetStatus() = *status
+	return reconcile.Result{}, t.Status().Update(ctx, template)
+}
+
+// validate checks the template's parameters and body, recording what it finds within the body in the
+// status, and returns the Valid condition describing the outcome
+func (t *templateController) validate(template v1alpha1.TemplateObject, status *v1alpha1.TemplateStatus) metav1.Condition {
+	spec := template.GetSpec()
+	condition := metav1.Condition{
+		Type:               v1alpha1.TypeValid,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: template.GetGeneration(),
+	}
+
+	if err := parameter.Validate(spec.Parameters); err != nil {
+		condition.Reason = v1alpha1.ReasonParametersInvalid
+		condition.Message = err.Error()
+		return condition
+	}
+
+	options, err := templatePkg.OptionsFor(*spec)
+	if err != nil {
+		condition.Reason = v1alpha1.ReasonTemplateBodyInvalid
+		condition.Message = fmt.Sprintf("failed to determine how to evaluate the body: %s", err.Error())
+		return condition
+	}
+
+	inspection, err := templatePkg.Inspect(strings.NewReader(spec.Body), status.DeclaredParameters, options...)
+	if err != nil {
+		condition.Reason = v1alpha1.ReasonTemplateBodyInvalid
+		condition.Message = err.Error()
+		return condition
+	}
+	status.DiscoveredParameters = inspection.Parameters
+	status.Manifests = inspection.Manifests
+
+	messages := []string{fmt.Sprintf("%d manifests", inspection.Manifests)}
+	if unused := inspection.Unused(status.DeclaredParameters); len(unused) != 0 {
+		messages = append(messages, "declared parameters not found in the body: "+strings.Join(unused, ", "))
+	}
+	if undeclared := inspection.Undeclared(status.DeclaredParameters); len(undeclared) != 0 && len(status.DeclaredParameters) != 0 {
+		messages = append(messages, "parameters found in the body but not declared: "+strings.Join(undeclared, ", "))
+	}
+
+	condition.Status = metav1.ConditionTrue
+	condition.Reason = v1alpha1.ReasonValidated
+	condition.Message = strings.Join(messages, "; ")
+	return condition
 }
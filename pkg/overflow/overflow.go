@@ -0,0 +1,324 @@
+package overflow
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	"github.com/operator-framework/combo/api/v1alpha1"
+)
+
+// DefaultChunkSize is the largest number of bytes of evaluations stored in a single ConfigMap by default,
+// which leaves plenty of room below the size limit of an object
+const DefaultChunkSize = 512 * 1024
+
+// keyPrefix prefixes the number of each evaluation to form its key within a ConfigMap
+const keyPrefix = "evaluation-"
+
+// Specify which errors this package can return
+var (
+	ErrWriteFailed = errors.New("failed to store evaluations")
+	ErrReadFailed  = errors.New("failed to read evaluations")
+)
+
+// New creates a store of the evaluations of the given combination in ConfigMaps within the given namespace,
+// or in Secrets when they hold sensitive values
+func New(client client.Client, owner v1alpha1.CombinationObject, namespace string, options ...StoreOption) Store {
+	s := Store{
+		client:    client,
+		owner:     owner,
+		namespace: namespace,
+		chunkSize: DefaultChunkSize,
+	}
+	for _, option := range options {
+		option(&s)
+	}
+	return s
+}
+
+type Store struct {
+	client    client.Client
+	owner     v1alpha1.CombinationObject
+	namespace string
+	chunkSize int
+	secrets   bool
+}
+
+type StoreOption func(*Store)
+
+// WithChunkSize limits the size of the evaluations stored in a single ConfigMap to n bytes.
+// Evaluations larger than that are stored in a ConfigMap of their own.
+func WithChunkSize(n int) StoreOption {
+	return func(s *Store) {
+		s.chunkSize = n
+	}
+}
+
+// WithSecrets stores the evaluations in Secrets instead of ConfigMaps, for evaluations holding values
+// sourced from Secrets that shouldn't be readable by anyone who can read ConfigMaps
+func WithSecrets() StoreOption {
+	return func(s *Store) {
+		s.secrets = true
+	}
+}
+
+// Write stores the evaluations in chunks and returns the reference to them to record in the combination's status.
+// Since each chunk is named after its content, chunks that are already stored are only adopted by the combination.
+func (s *Store) Write(ctx context.Context, evaluations []string) (*v1alpha1.EvaluationsOverflow, error) {
+	if s.namespace == "" {
+		return nil, fmt.Errorf("%w: no namespace to store them in", ErrWriteFailed)
+	}
+
+	overflow := &v1alpha1.EvaluationsOverflow{
+		Namespace: s.namespace,
+		Count:     len(evaluations),
+		Size:      Size(evaluations),
+		Digest:    Digest(evaluations),
+	}
+	names := []string{}
+
+	offset := 0
+	for _, chunk := range Chunk(evaluations, s.chunkSize) {
+		data := make(map[string]string, len(chunk))
+		for i, evaluation := range chunk {
+			data[keyPrefix+strconv.Itoa(offset+i)] = evaluation
+		}
+		offset += len(chunk)
+
+		name := s.nameFor(Digest(chunk), offset)
+		if err := s.ensure(ctx, name, data); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrWriteFailed, err.Error())
+		}
+		names = append(names, name)
+	}
+
+	if s.secrets {
+		overflow.Secrets = names
+	} else {
+		overflow.ConfigMaps = names
+	}
+	return overflow, nil
+}
+
+// nameFor returns the name of the object holding the chunk of the given digest, which ends at the given
+// offset. The offset is part of the name since the keys of the evaluations within a chunk depend on it.
+func (s *Store) nameFor(digest string, offset int) string {
+	suffix := fmt.Sprintf("-%s-%d", strings.TrimPrefix(digest, "sha256:")[:16], offset)
+	prefix := s.owner.GetName()
+	if max := 253 - len(suffix); len(prefix) > max {
+		prefix = strings.TrimRight(prefix[:max], ".-")
+	}
+	return prefix + suffix
+}
+
+// ensure creates the immutable ConfigMap or Secret holding a chunk, or adds the combination to the owners of an existing one
+func (s *Store) ensure(ctx context.Context, name string, data map[string]string) error {
+	immutable := true
+	meta := metav1.ObjectMeta{Namespace: s.namespace, Name: name}
+	var chunk client.Object = &corev1.ConfigMap{ObjectMeta: meta, Data: data, Immutable: &immutable}
+	if s.secrets {
+		secretData := make(map[string][]byte, len(data))
+		for key, value := range data {
+			secretData[key] = []byte(value)
+		}
+		chunk = &corev1.Secret{ObjectMeta: meta, Data: secretData, Immutable: &immutable}
+	}
+	if err := controllerutil.SetOwnerReference(s.owner, chunk, s.client.Scheme()); err != nil {
+		return err
+	}
+
+	err := s.client.Create(ctx, chunk)
+	if !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+
+	existing := newChunk(s.secrets)
+	if err := s.client.Get(ctx, client.ObjectKeyFromObject(chunk), existing); err != nil {
+		return err
+	}
+	if isOwnedBy(existing, s.owner) {
+		return nil
+	}
+	if err := controllerutil.SetOwnerReference(s.owner, existing, s.client.Scheme()); err != nil {
+		return err
+	}
+	return s.client.Update(ctx, existing)
+}
+
+// Prune releases the ConfigMaps and Secrets in the previous overflow that aren't part of the current one,
+// deleting those that no other combination owns. Either overflow may be nil.
+func (s *Store) Prune(ctx context.Context, previous, current *v1alpha1.EvaluationsOverflow) error {
+	if previous == nil {
+		return nil
+	}
+
+	type chunkKey struct {
+		types.NamespacedName
+		secret bool
+	}
+	keep := map[chunkKey]struct{}{}
+	if current != nil {
+		for _, name := range current.ConfigMaps {
+			keep[chunkKey{types.NamespacedName{Namespace: current.Namespace, Name: name}, false}] = struct{}{}
+		}
+		for _, name := range current.Secrets {
+			keep[chunkKey{types.NamespacedName{Namespace: current.Namespace, Name: name}, true}] = struct{}{}
+		}
+	}
+
+	var failed []string
+	for secret, names := range map[bool][]string{false: previous.ConfigMaps, true: previous.Secrets} {
+		for _, name := range names {
+			key := chunkKey{types.NamespacedName{Namespace: previous.Namespace, Name: name}, secret}
+			if _, ok := keep[key]; ok {
+				continue
+			}
+			if err := s.release(ctx, key.NamespacedName, secret); err != nil {
+				failed = append(failed, fmt.Sprintf("%s: %s", key.NamespacedName, err.Error()))
+			}
+		}
+	}
+	sort.Strings(failed)
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to prune stored evaluations: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// release removes the combination from the owners of the ConfigMap or Secret, deleting it once it has no owners left
+func (s *Store) release(ctx context.Context, key types.NamespacedName, secret bool) error {
+	chunk := newChunk(secret)
+	if err := s.client.Get(ctx, key, chunk); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !isOwnedBy(chunk, s.owner) {
+		return nil
+	}
+
+	var owners []metav1.OwnerReference
+	for _, ref := range chunk.GetOwnerReferences() {
+		if ref.UID != s.owner.GetUID() {
+			owners = append(owners, ref)
+		}
+	}
+	if len(owners) == 0 {
+		return client.IgnoreNotFound(s.client.Delete(ctx, chunk))
+	}
+
+	chunk.SetOwnerReferences(owners)
+	return s.client.Update(ctx, chunk)
+}
+
+// newChunk returns an empty object of the kind holding a chunk, either a Secret or a ConfigMap
+func newChunk(secret bool) client.Object {
+	if secret {
+		return &corev1.Secret{}
+	}
+	return &corev1.ConfigMap{}
+}
+
+// isOwnedBy determines whether the owner is among the owners of the object
+func isOwnedBy(object metav1.Object, owner metav1.Object) bool {
+	for _, ref := range object.GetOwnerReferences() {
+		if ref.UID == owner.GetUID() {
+			return true
+		}
+	}
+	return false
+}
+
+// Read returns the evaluations stored in the ConfigMaps or Secrets referenced by the overflow, in order,
+// and ensures they still match its digest
+func Read(ctx context.Context, reader client.Reader, overflow v1alpha1.EvaluationsOverflow) ([]string, error) {
+	secret := len(overflow.Secrets) != 0
+	names := overflow.ConfigMaps
+	if secret {
+		names = overflow.Secrets
+	}
+
+	evaluations := make([]string, 0, overflow.Count)
+	for _, name := range names {
+		chunk := newChunk(secret)
+		if err := reader.Get(ctx, types.NamespacedName{Namespace: overflow.Namespace, Name: name}, chunk); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrReadFailed, err.Error())
+		}
+
+		for i := len(evaluations); ; i++ {
+			evaluation, ok := chunkData(chunk, keyPrefix+strconv.Itoa(i))
+			if !ok {
+				break
+			}
+			evaluations = append(evaluations, evaluation)
+		}
+	}
+
+	if len(evaluations) != overflow.Count || Digest(evaluations) != overflow.Digest {
+		return nil, fmt.Errorf("%w: the stored evaluations don't match the digest %s", ErrReadFailed, overflow.Digest)
+	}
+	return evaluations, nil
+}
+
+// chunkData returns the evaluation stored under the key of a ConfigMap or Secret
+func chunkData(chunk client.Object, key string) (string, bool) {
+	switch chunk := chunk.(type) {
+	case *corev1.Secret:
+		data, ok := chunk.Data[key]
+		return string(data), ok
+	case *corev1.ConfigMap:
+		data, ok := chunk.Data[key]
+		return data, ok
+	}
+	return "", false
+}
+
+// Chunk splits the evaluations, in order, into chunks holding at most size bytes of evaluations each,
+// unless a single evaluation is larger than that
+func Chunk(evaluations []string, size int) [][]string {
+	var chunks [][]string
+	var chunk []string
+	var chunkSize int
+	for _, evaluation := range evaluations {
+		if len(chunk) > 0 && chunkSize+len(evaluation) > size {
+			chunks = append(chunks, chunk)
+			chunk, chunkSize = nil, 0
+		}
+		chunk = append(chunk, evaluation)
+		chunkSize += len(evaluation)
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// Size returns the total size of the evaluations in bytes
+func Size(evaluations []string) int {
+	var size int
+	for _, evaluation := range evaluations {
+		size += len(evaluation)
+	}
+	return size
+}
+
+// Digest returns the SHA-256 digest of the evaluations, prefixed with sha256:. Each evaluation is prefixed
+// with its length, so the digest tells apart evaluations that would otherwise concatenate the same way.
+func Digest(evaluations []string) string {
+	hash := sha256.New()
+	for _, evaluation := range evaluations {
+		fmt.Fprintf(hash, "%d:%s", len(evaluation), evaluation)
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil))
+}
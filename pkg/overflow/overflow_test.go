@@ -0,0 +1,180 @@
+package overflow
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/operator-framework/combo/api/v1alpha1"
+)
+
+func TestChunk(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		evaluations []string
+		size        int
+		expected    [][]string
+	}{
+		{
+			name:        "fills chunks up to the size",
+			evaluations: []string{"aa", "bb", "cc", "dd", "ee"},
+			size:        4,
+			expected:    [][]string{{"aa", "bb"}, {"cc", "dd"}, {"ee"}},
+		},
+		{
+			name:        "keeps evaluations larger than the size on their own",
+			evaluations: []string{"a", "bbbbbb", "c"},
+			size:        4,
+			expected:    [][]string{{"a"}, {"bbbbbb"}, {"c"}},
+		},
+		{
+			name: "chunks nothing",
+			size: 4,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, Chunk(tt.evaluations, tt.size))
+		})
+	}
+}
+
+func TestDigest(t *testing.T) {
+	require.Equal(t, Digest([]string{"foo", "bar"}), Digest([]string{"foo", "bar"}))
+	require.NotEqual(t, Digest([]string{"foo", "bar"}), Digest([]string{"bar", "foo"}))
+	require.NotEqual(t, Digest([]string{"foo", "bar"}), Digest([]string{"foob", "ar"}))
+	require.True(t, strings.HasPrefix(Digest(nil), "sha256:"))
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+	cli := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	combination := func(name string) *v1alpha1.Combination {
+		return &v1alpha1.Combination{ObjectMeta: metav1.ObjectMeta{Name: name, UID: types.UID(name)}}
+	}
+	foo, bar := combination("foo"), combination("bar")
+	evaluations := []string{"first: 1", "second: 2", "third: 3"}
+
+	// Each chunk is stored in a ConfigMap owned by the combination
+	store := New(cli, foo, "combo", WithChunkSize(17))
+	overflow, err := store.Write(ctx, evaluations)
+	require.NoError(t, err)
+	require.Equal(t, "combo", overflow.Namespace)
+	require.Len(t, overflow.ConfigMaps, 2)
+	require.Equal(t, 3, overflow.Count)
+	require.Equal(t, Size(evaluations), overflow.Size)
+	require.Equal(t, Digest(evaluations), overflow.Digest)
+
+	read, err := Read(ctx, cli, *overflow)
+	require.NoError(t, err)
+	require.Equal(t, evaluations, read)
+
+	// Writing the same evaluations again leaves the ConfigMaps as they are
+	again, err := store.Write(ctx, evaluations)
+	require.NoError(t, err)
+	require.Equal(t, overflow, again)
+
+	// Pruning releases the ConfigMaps that are no longer part of the evaluations
+	changed, err := store.Write(ctx, evaluations[:2])
+	require.NoError(t, err)
+	require.NoError(t, store.Prune(ctx, overflow, changed))
+	for _, name := range overflow.ConfigMaps {
+		err := cli.Get(ctx, types.NamespacedName{Namespace: "combo", Name: name}, &corev1.ConfigMap{})
+		if name == changed.ConfigMaps[0] {
+			require.NoError(t, err, "%s should not have been pruned", name)
+		} else {
+			require.True(t, apierrors.IsNotFound(err), "%s should have been pruned", name)
+		}
+	}
+
+	// ConfigMaps are only deleted once no combination owns them
+	configMap := &corev1.ConfigMap{}
+	key := types.NamespacedName{Namespace: "combo", Name: changed.ConfigMaps[0]}
+	require.NoError(t, cli.Get(ctx, key, configMap))
+	configMap.OwnerReferences = append(configMap.OwnerReferences, metav1.OwnerReference{
+		APIVersion: v1alpha1.GroupVersion.String(),
+		Kind:       "Combination",
+		Name:       bar.Name,
+		UID:        bar.UID,
+	})
+	require.NoError(t, cli.Update(ctx, configMap))
+
+	require.NoError(t, store.Prune(ctx, changed, nil))
+	require.NoError(t, cli.Get(ctx, key, configMap))
+	require.Len(t, configMap.OwnerReferences, 1)
+	require.Equal(t, bar.UID, configMap.OwnerReferences[0].UID)
+
+	barStore := New(cli, bar, "combo")
+	require.NoError(t, barStore.Prune(ctx, changed, nil))
+	require.True(t, apierrors.IsNotFound(cli.Get(ctx, key, configMap)))
+}
+
+func TestStoreSecrets(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+	cli := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	foo := &v1alpha1.Combination{ObjectMeta: metav1.ObjectMeta{Name: "foo", UID: "foo"}}
+	evaluations := []string{"token: secret", "token: other"}
+
+	// Sensitive evaluations are stored in Secrets only
+	store := New(cli, foo, "combo", WithSecrets())
+	overflow, err := store.Write(ctx, evaluations)
+	require.NoError(t, err)
+	require.Empty(t, overflow.ConfigMaps)
+	require.Len(t, overflow.Secrets, 1)
+	require.NoError(t, cli.Get(ctx, types.NamespacedName{Namespace: "combo", Name: overflow.Secrets[0]}, &corev1.Secret{}))
+	require.True(t, apierrors.IsNotFound(cli.Get(ctx, types.NamespacedName{Namespace: "combo", Name: overflow.Secrets[0]}, &corev1.ConfigMap{})))
+
+	read, err := Read(ctx, cli, *overflow)
+	require.NoError(t, err)
+	require.Equal(t, evaluations, read)
+
+	// Storing the evaluations in ConfigMaps instead prunes the Secrets
+	configMapStore := New(cli, foo, "combo")
+	changed, err := configMapStore.Write(ctx, evaluations)
+	require.NoError(t, err)
+	require.Len(t, changed.ConfigMaps, 1)
+	require.Empty(t, changed.Secrets)
+	require.NoError(t, store.Prune(ctx, overflow, changed))
+	require.True(t, apierrors.IsNotFound(cli.Get(ctx, types.NamespacedName{Namespace: "combo", Name: overflow.Secrets[0]}, &corev1.Secret{})))
+	require.NoError(t, cli.Get(ctx, types.NamespacedName{Namespace: "combo", Name: changed.ConfigMaps[0]}, &corev1.ConfigMap{}))
+}
+
+func TestRead(t *testing.T) {
+	cli := fake.NewClientBuilder().WithObjects(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "combo", Name: "foo-chunk"},
+		Data:       map[string]string{"evaluation-0": "first", "evaluation-1": "second"},
+	}).Build()
+
+	overflow := v1alpha1.EvaluationsOverflow{
+		Namespace:  "combo",
+		ConfigMaps: []string{"foo-chunk"},
+		Count:      2,
+		Digest:     Digest([]string{"first", "second"}),
+	}
+	evaluations, err := Read(context.Background(), cli, overflow)
+	require.NoError(t, err)
+	require.Equal(t, []string{"first", "second"}, evaluations)
+
+	overflow.Digest = Digest([]string{"first", "changed"})
+	_, err = Read(context.Background(), cli, overflow)
+	require.ErrorIs(t, err, ErrReadFailed)
+
+	overflow.ConfigMaps = []string{"missing"}
+	_, err = Read(context.Background(), cli, overflow)
+	require.ErrorIs(t, err, ErrReadFailed)
+}
@@ -0,0 +1,226 @@
+package parameter
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/operator-framework/combo/api/v1alpha1"
+	apivalidation "k8s.io/apimachinery/pkg/api/validation"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Specify which errors this package can return
+var (
+	ErrInvalidArguments  = errors.New("invalid arguments")
+	ErrInvalidParameters = errors.New("invalid parameters")
+	ErrArgumentsMismatch = errors.New("arguments do not match parameters")
+)
+
+// namePattern matches the names parameters may have, which are identifiers since they're searched for within
+// template bodies and referenced as fields by the GoTemplate engine
+var namePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Match ensures that the arguments are for the declared parameters: every key of args must be a
+// parameter and every required parameter without a default must be a key of args, even if it has no
+// values, as when an argument selects objects that don't exist yet. Nothing is checked when no
+// parameters are declared.
+func Match(parameters []v1alpha1.Parameter, args map[string][]string) error {
+	if len(parameters) == 0 {
+		return nil
+	}
+
+	declared := map[string]struct{}{}
+	var missing []string
+	for _, parameter := range parameters {
+		declared[parameter.Name] = struct{}{}
+		if _, ok := args[parameter.Name]; !ok && parameter.Required && parameter.Default == nil {
+			missing = append(missing, parameter.Name)
+		}
+	}
+
+	var unknown []string
+	for key := range args {
+		if _, ok := declared[key]; !ok {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+
+	var mismatches []string
+	if len(unknown) != 0 {
+		mismatches = append(mismatches, "unknown keys: "+strings.Join(unknown, ", "))
+	}
+	if len(missing) != 0 {
+		mismatches = append(mismatches, "missing keys: "+strings.Join(missing, ", "))
+	}
+	if len(mismatches) != 0 {
+		return fmt.Errorf("%w: %s", ErrArgumentsMismatch, strings.Join(mismatches, "; "))
+	}
+	return nil
+}
+
+// Resolve checks the arguments against the rules of each parameter and returns them with the
+// default of every parameter that isn't a key of args filled in, or an empty value for optional
+// parameters without a default, so they're never left unreplaced. Arguments for keys that aren't
+// parameters are returned as they are. Every violation is reported together in a single error.
+func Resolve(parameters []v1alpha1.Parameter, args map[string][]string) (map[string][]string, error) {
+	if err := Validate(parameters); err != nil {
+		return nil, err
+	}
+
+	resolved := make(map[string][]string, len(args))
+	for key, values := range args {
+		resolved[key] = values
+	}
+
+	var violations []string
+	for _, parameter := range parameters {
+		values, ok := resolved[parameter.Name]
+		if !ok {
+			switch {
+			case parameter.Default != nil:
+				resolved[parameter.Name] = []string{*parameter.Default}
+			case parameter.Required:
+				violations = append(violations, fmt.Sprintf("%s is required", parameter.Name))
+			default:
+				resolved[parameter.Name] = []string{""}
+			}
+			continue
+		}
+
+		for _, value := range values {
+			if err := check(parameter, value); err != nil {
+				violations = append(violations, fmt.Sprintf("%s: %s", parameter.Name, err.Error()))
+			}
+		}
+	}
+
+	if len(violations) != 0 {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidArguments, strings.Join(violations, "; "))
+	}
+	return resolved, nil
+}
+
+// Validate ensures that the parameters are well defined: each parameter has a unique name that is an
+// identifier and a valid pattern, and its default follows its own rules.
+func Validate(parameters []v1alpha1.Parameter) error {
+	var violations []string
+	names := map[string]struct{}{}
+	for _, parameter := range parameters {
+		if parameter.Name == "" {
+			violations = append(violations, "a parameter has no name")
+			continue
+		}
+		if !namePattern.MatchString(parameter.Name) {
+			violations = append(violations, fmt.Sprintf("%q is not a valid parameter name, must match %s", parameter.Name, namePattern.String()))
+			continue
+		}
+		if _, ok := names[parameter.Name]; ok {
+			violations = append(violations, fmt.Sprintf("%s is defined more than once", parameter.Name))
+		}
+		names[parameter.Name] = struct{}{}
+
+		if _, ok := validators[typeOf(parameter)]; !ok {
+			violations = append(violations, fmt.Sprintf("%s has an unknown type %q", parameter.Name, parameter.Type))
+			continue
+		}
+		if _, err := compile(parameter.Pattern); err != nil {
+			violations = append(violations, fmt.Sprintf("%s has an invalid pattern: %s", parameter.Name, err.Error()))
+			continue
+		}
+		if parameter.Default != nil {
+			if err := check(parameter, *parameter.Default); err != nil {
+				violations = append(violations, fmt.Sprintf("%s has an invalid default: %s", parameter.Name, err.Error()))
+			}
+		}
+	}
+
+	if len(violations) != 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidParameters, strings.Join(violations, "; "))
+	}
+	return nil
+}
+
+// validators check that a value has the type they are keyed by
+var validators = map[string]func(value string) error{
+	v1alpha1.ParameterTypeString: func(string) error {
+		return nil
+	},
+	v1alpha1.ParameterTypeInt: func(value string) error {
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("%q is not an int", value)
+		}
+		return nil
+	},
+	v1alpha1.ParameterTypeBool: func(value string) error {
+		if value != "true" && value != "false" {
+			return fmt.Errorf("%q is not a bool, must be true or false", value)
+		}
+		return nil
+	},
+	v1alpha1.ParameterTypeNamespaceName: func(value string) error {
+		if errs := apivalidation.ValidateNamespaceName(value, false); len(errs) != 0 {
+			return fmt.Errorf("%q is not a namespace name: %s", value, strings.Join(errs, ", "))
+		}
+		return nil
+	},
+	v1alpha1.ParameterTypeDNSLabel: func(value string) error {
+		if errs := validation.IsDNS1123Label(value); len(errs) != 0 {
+			return fmt.Errorf("%q is not a dns label: %s", value, strings.Join(errs, ", "))
+		}
+		return nil
+	},
+}
+
+// typeOf returns the type of the parameter, which is a string unless specified
+func typeOf(parameter v1alpha1.Parameter) string {
+	if parameter.Type == "" {
+		return v1alpha1.ParameterTypeString
+	}
+	return parameter.Type
+}
+
+// compile compiles the pattern so that it must match values in full, or returns nil if there is no pattern
+func compile(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile(`^(?:` + pattern + `)$`)
+}
+
+// check ensures the value has the parameter's type, is one of its enum and matches its pattern
+func check(parameter v1alpha1.Parameter, value string) error {
+	validate, ok := validators[typeOf(parameter)]
+	if !ok {
+		return fmt.Errorf("unknown type %q", parameter.Type)
+	}
+	if err := validate(value); err != nil {
+		return err
+	}
+
+	if len(parameter.Enum) != 0 {
+		found := false
+		for _, allowed := range parameter.Enum {
+			if value == allowed {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%q is not one of %s", value, strings.Join(parameter.Enum, ", "))
+		}
+	}
+
+	pattern, err := compile(parameter.Pattern)
+	if err != nil {
+		return fmt.Errorf("invalid pattern: %s", err.Error())
+	}
+	if pattern != nil && !pattern.MatchString(value) {
+		return fmt.Errorf("%q does not match %s", value, parameter.Pattern)
+	}
+	return nil
+}
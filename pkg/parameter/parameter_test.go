@@ -0,0 +1,231 @@
+package parameter
+
+import (
+	"testing"
+
+	"github.com/operator-framework/combo/api/v1alpha1"
+	"github.com/stretchr/testify/require"
+)
+
+func pointerTo(s string) *string {
+	return &s
+}
+
+func TestResolve(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		parameters []v1alpha1.Parameter
+		args       map[string][]string
+		expected   map[string][]string
+		err        error
+	}{
+		{
+			name:       "accepts untyped parameters",
+			parameters: []v1alpha1.Parameter{{Name: "NAME"}},
+			args:       map[string][]string{"NAME": {"foo", "Bar:baz"}},
+			expected:   map[string][]string{"NAME": {"foo", "Bar:baz"}},
+		},
+		{
+			name: "fills in defaults",
+			parameters: []v1alpha1.Parameter{
+				{Name: "NAME", Default: pointerTo("foo")},
+				{Name: "REPLICAS", Type: v1alpha1.ParameterTypeInt, Default: pointerTo("1")},
+			},
+			args:     map[string][]string{"REPLICAS": {"3"}, "OTHER": {"bar"}},
+			expected: map[string][]string{"NAME": {"foo"}, "REPLICAS": {"3"}, "OTHER": {"bar"}},
+		},
+		{
+			name: "accepts values of each type",
+			parameters: []v1alpha1.Parameter{
+				{Name: "STRING", Type: v1alpha1.ParameterTypeString},
+				{Name: "INT", Type: v1alpha1.ParameterTypeInt},
+				{Name: "BOOL", Type: v1alpha1.ParameterTypeBool},
+				{Name: "NAMESPACE", Type: v1alpha1.ParameterTypeNamespaceName},
+				{Name: "LABEL", Type: v1alpha1.ParameterTypeDNSLabel},
+			},
+			args: map[string][]string{
+				"STRING":    {"system:serviceaccounts"},
+				"INT":       {"-1", "42"},
+				"BOOL":      {"true", "false"},
+				"NAMESPACE": {"kube-system"},
+				"LABEL":     {"feature-1"},
+			},
+			expected: map[string][]string{
+				"STRING":    {"system:serviceaccounts"},
+				"INT":       {"-1", "42"},
+				"BOOL":      {"true", "false"},
+				"NAMESPACE": {"kube-system"},
+				"LABEL":     {"feature-1"},
+			},
+		},
+		{
+			name: "rejects values of the wrong type",
+			parameters: []v1alpha1.Parameter{
+				{Name: "INT", Type: v1alpha1.ParameterTypeInt},
+				{Name: "BOOL", Type: v1alpha1.ParameterTypeBool},
+				{Name: "NAMESPACE", Type: v1alpha1.ParameterTypeNamespaceName},
+				{Name: "LABEL", Type: v1alpha1.ParameterTypeDNSLabel},
+			},
+			args: map[string][]string{
+				"INT":       {"1.5"},
+				"BOOL":      {"yes"},
+				"NAMESPACE": {"Kube_System"},
+				"LABEL":     {"feature.1"},
+			},
+			err: ErrInvalidArguments,
+		},
+		{
+			name:       "rejects values outside of the enum",
+			parameters: []v1alpha1.Parameter{{Name: "ENV", Enum: []string{"dev", "prod"}}},
+			args:       map[string][]string{"ENV": {"dev", "staging"}},
+			err:        ErrInvalidArguments,
+		},
+		{
+			name:       "rejects values that only partially match the pattern",
+			parameters: []v1alpha1.Parameter{{Name: "VERSION", Pattern: `v[0-9]+`}},
+			args:       map[string][]string{"VERSION": {"v1", "v2beta1"}},
+			err:        ErrInvalidArguments,
+		},
+		{
+			name:       "rejects a missing required parameter",
+			parameters: []v1alpha1.Parameter{{Name: "NAME", Required: true}},
+			args:       map[string][]string{"OTHER": {"foo"}},
+			err:        ErrInvalidArguments,
+		},
+		{
+			name:       "fills in an empty value for a missing optional parameter without a default",
+			parameters: []v1alpha1.Parameter{{Name: "SUFFIX", Type: v1alpha1.ParameterTypeDNSLabel}, {Name: "NAME"}},
+			args:       map[string][]string{"NAME": {"foo"}},
+			expected:   map[string][]string{"NAME": {"foo"}, "SUFFIX": {""}},
+		},
+		{
+			name:       "accepts a missing required parameter with a default",
+			parameters: []v1alpha1.Parameter{{Name: "NAME", Required: true, Default: pointerTo("")}},
+			args:       map[string][]string{},
+			expected:   map[string][]string{"NAME": {""}},
+		},
+		{
+			name:       "rejects invalid parameters",
+			parameters: []v1alpha1.Parameter{{Name: "NAME", Pattern: `(`}},
+			args:       map[string][]string{"NAME": {"foo"}},
+			err:        ErrInvalidParameters,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			resolved, err := Resolve(tt.parameters, tt.args)
+			require.ErrorIs(t, err, tt.err)
+			require.Equal(t, tt.expected, resolved)
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	parameters := []v1alpha1.Parameter{
+		{Name: "NAME"},
+		{Name: "NAMESPACE", Default: pointerTo("default")},
+		{Name: "GROUP", Required: true},
+	}
+
+	for _, tt := range []struct {
+		name       string
+		parameters []v1alpha1.Parameter
+		args       map[string][]string
+		err        error
+		message    string
+	}{
+		{
+			name:       "matches arguments for every parameter",
+			parameters: parameters,
+			args:       map[string][]string{"NAME": {"foo"}, "NAMESPACE": {"bar"}, "GROUP": {"baz"}},
+		},
+		{
+			name:       "does not require arguments for parameters with a default",
+			parameters: parameters,
+			args:       map[string][]string{"NAME": {"foo"}, "GROUP": {"baz"}},
+		},
+		{
+			name:       "reports unknown and missing keys",
+			parameters: parameters,
+			args:       map[string][]string{"NAMSPACE": {"bar"}, "GRUOP": {"baz"}},
+			err:        ErrArgumentsMismatch,
+			message:    "arguments do not match parameters: unknown keys: GRUOP, NAMSPACE; missing keys: GROUP",
+		},
+		{
+			name:       "does not require arguments for optional parameters without a default",
+			parameters: parameters,
+			args:       map[string][]string{"GROUP": {"baz"}},
+		},
+		{
+			name:       "matches arguments without values",
+			parameters: parameters,
+			args:       map[string][]string{"NAME": {}, "GROUP": {}},
+		},
+		{
+			name: "does not check arguments without any declared parameters",
+			args: map[string][]string{"NAME": {"foo"}},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Match(tt.parameters, tt.args)
+			require.ErrorIs(t, err, tt.err)
+			if tt.message != "" {
+				require.EqualError(t, err, tt.message)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		parameters []v1alpha1.Parameter
+		err        error
+	}{
+		{
+			name: "accepts valid parameters",
+			parameters: []v1alpha1.Parameter{
+				{Name: "NAME", Description: "the name", Default: pointerTo("foo"), Pattern: `[a-z]+`},
+				{Name: "ENV", Enum: []string{"dev", "prod"}, Required: true},
+			},
+		},
+		{
+			name:       "rejects a duplicate parameter",
+			parameters: []v1alpha1.Parameter{{Name: "NAME"}, {Name: "NAME"}},
+			err:        ErrInvalidParameters,
+		},
+		{
+			name:       "rejects a name with whitespace",
+			parameters: []v1alpha1.Parameter{{Name: "TARGET NAME"}},
+			err:        ErrInvalidParameters,
+		},
+		{
+			name:       "rejects a name with regular expression metacharacters",
+			parameters: []v1alpha1.Parameter{{Name: "FOO("}},
+			err:        ErrInvalidParameters,
+		},
+		{
+			name:       "rejects a name starting with a digit",
+			parameters: []v1alpha1.Parameter{{Name: "1NAME"}},
+			err:        ErrInvalidParameters,
+		},
+		{
+			name:       "rejects an unknown type",
+			parameters: []v1alpha1.Parameter{{Name: "NAME", Type: "float"}},
+			err:        ErrInvalidParameters,
+		},
+		{
+			name:       "rejects an invalid pattern",
+			parameters: []v1alpha1.Parameter{{Name: "NAME", Pattern: `[a-z`}},
+			err:        ErrInvalidParameters,
+		},
+		{
+			name:       "rejects a default that breaks the parameter's own rules",
+			parameters: []v1alpha1.Parameter{{Name: "REPLICAS", Type: v1alpha1.ParameterTypeInt, Default: pointerTo("many")}},
+			err:        ErrInvalidParameters,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorIs(t, Validate(tt.parameters), tt.err)
+		})
+	}
+}
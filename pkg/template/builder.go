@@ -2,13 +2,18 @@ package template
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
+
+	"github.com/operator-framework/combo/api/v1alpha1"
 )
 
 type (
 	Builder interface {
 		Build(ctx context.Context) ([]string, error)
+		Evaluate(ctx context.Context) ([]Evaluation, error)
 	}
 
 	CombinationStream interface {
@@ -19,37 +24,165 @@ type (
 type builder struct {
 	combinations CombinationStream
 	template     template
+	substitution Substitution
+	delimiters   *Delimiters
+	engine       Engine
 }
 
-func NewBuilder(file io.Reader, combinations CombinationStream) (Builder, error) {
-	compiledTemplate, err := newTemplate(file)
+type BuilderOption func(*builder)
+
+func NewBuilder(file io.Reader, combinations CombinationStream, options ...BuilderOption) (Builder, error) {
+	b := newBuilder(options...)
+	compiledTemplate, err := b.compile(file)
 	if err != nil {
 		return nil, fmt.Errorf("failed to build template: %w", err)
 	}
-	return &builder{
-		template:     compiledTemplate,
-		combinations: combinations,
-	}, nil
+	b.template = compiledTemplate
+	b.combinations = combinations
+	return b, nil
+}
+
+// newBuilder creates a builder with the default options overridden by the given options
+func newBuilder(options ...BuilderOption) *builder {
+	b := &builder{
+		substitution: SubstitutionText,
+		engine:       EngineReplace,
+	}
+	for _, option := range options {
+		option(b)
+	}
+	return b
+}
+
+// compile validates the builder's options and compiles the file into a template evaluated as they describe
+func (b *builder) compile(file io.Reader) (template, error) {
+	if b.substitution != SubstitutionText && b.substitution != SubstitutionYAML {
+		return template{}, fmt.Errorf("%w: unknown substitution %q", ErrInvalidSubstitution, b.substitution)
+	}
+	if b.delimiters != nil && (b.delimiters.Left == "" || b.delimiters.Right == "") {
+		return template{}, fmt.Errorf("%w: both delimiters must be set", ErrInvalidDelimiters)
+	}
+
+	if b.engine != EngineReplace && b.engine != EngineGoTemplate {
+		return template{}, fmt.Errorf("%w: unknown engine %q", ErrInvalidEngine, b.engine)
+	}
+	if b.engine == EngineGoTemplate && b.substitution != SubstitutionText {
+		return template{}, fmt.Errorf("%w: %s substitution is not supported by the %s engine", ErrInvalidEngine, b.substitution, b.engine)
+	}
+
+	var compiledTemplate template
+	var err error
+	if b.engine == EngineGoTemplate {
+		compiledTemplate, err = newGoTemplate(file, b.delimiters)
+	} else {
+		compiledTemplate, err = newTemplate(file)
+	}
+	if err != nil {
+		return template{}, err
+	}
+	compiledTemplate.substitution = b.substitution
+	compiledTemplate.delimiters = b.delimiters
+	return compiledTemplate, nil
+}
+
+// WithSubstitution specifies how parameters are replaced within the template's manifests.
+// By default, SubstitutionText is used.
+func WithSubstitution(substitution Substitution) BuilderOption {
+	return func(b *builder) {
+		b.substitution = substitution
+	}
+}
+
+// WithDelimiters specifies the delimiters enclosing each parameter within the template, e.g. ${{ and }}.
+// By default, parameters aren't delimited and every occurrence of a parameter's name is replaced.
+func WithDelimiters(left, right string) BuilderOption {
+	return func(b *builder) {
+		b.delimiters = &Delimiters{Left: left, Right: right}
+	}
+}
+
+// WithEngine specifies how the template is evaluated with each combination. By default,
+// EngineReplace is used. With EngineGoTemplate, the delimiters replace text/template's
+// default {{ and }} and the substitution must be SubstitutionText.
+func WithEngine(engine Engine) BuilderOption {
+	return func(b *builder) {
+		b.engine = engine
+	}
+}
+
+// OptionsFor returns the builder options that evaluate the body of a template as its spec describes
+func OptionsFor(spec v1alpha1.TemplateSpec) ([]BuilderOption, error) {
+	engine, err := ParseEngine(spec.Engine)
+	if err != nil {
+		return nil, err
+	}
+
+	substitution, err := ParseSubstitution(spec.Substitution)
+	if err != nil {
+		return nil, err
+	}
+
+	options := []BuilderOption{WithEngine(engine), WithSubstitution(substitution)}
+	if spec.Delimiters != nil {
+		options = append(options, WithDelimiters(spec.Delimiters.Left, spec.Delimiters.Right))
+	}
+	return options, nil
+}
+
+// Evaluation is a manifest built by the builder along with what produced it
+type Evaluation struct {
+	// Manifest is the evaluated manifest
+	Manifest string
+
+	// Arguments is the combination the manifest was evaluated with. When several combinations evaluate to the
+	// same manifest, the manifest is only built once and this is the first of them.
+	Arguments map[string]string
+
+	// Index is the index of the manifest within the template that was evaluated, or within the manifests
+	// rendered with the combination when using EngineGoTemplate
+	Index int
+}
+
+// Hash returns the SHA-256 hash of the evaluation's manifest, prefixed with sha256:
+func (e Evaluation) Hash() string {
+	hash := sha256.Sum256([]byte(e.Manifest))
+	return "sha256:" + hex.EncodeToString(hash[:])
 }
 
 // Build uses the current builder's template and combination stream to
 // construct the combinations of manifests built together
 func (g *builder) Build(ctx context.Context) ([]string, error) {
+	evaluations, err := g.Evaluate(ctx)
+	if err != nil {
+		return []string{}, err
+	}
+
+	manifests := make([]string, 0, len(evaluations))
+	for _, evaluation := range evaluations {
+		manifests = append(manifests, evaluation.Manifest)
+	}
+	return manifests, nil
+}
+
+// Evaluate builds the same manifests as Build, in the same order, recording what produced each of them
+func (g *builder) Evaluate(ctx context.Context) ([]Evaluation, error) {
 	// Wait for the context to end or the combinations to be done
 	for {
 		select {
 		case <-ctx.Done():
-			return []string{}, ctx.Err()
+			return []Evaluation{}, ctx.Err()
 		default:
 			combination, err := g.combinations.Next(ctx)
 			if err != nil {
-				return []string{}, err
+				return []Evaluation{}, err
 			}
 
 			if combination == nil {
-				return g.template.processedManifests, nil
+				return g.template.evaluations, nil
+			}
+			if err := g.template.with(combination); err != nil {
+				return []Evaluation{}, err
 			}
-			g.template.with(combination)
 		}
 	}
 }
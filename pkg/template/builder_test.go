@@ -82,3 +82,48 @@ func TestBuild(t *testing.T) {
 		})
 	}
 }
+
+func TestEvaluate(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		file     string
+		options  []BuilderOption
+		expected []Evaluation
+	}{
+		{
+			name: "records the combination and manifest of each evaluation",
+			file: "kind: NAME\n---\nkind: Shared",
+			expected: []Evaluation{
+				{Manifest: "kind: foo", Arguments: map[string]string{"NAME": "foo"}, Index: 0},
+				{Manifest: "kind: Shared", Arguments: map[string]string{"NAME": "foo"}, Index: 1},
+				{Manifest: "kind: bar", Arguments: map[string]string{"NAME": "bar"}, Index: 0},
+			},
+		},
+		{
+			name:    "records the rendered manifest of each evaluation",
+			file:    "{{ if eq .NAME \"bar\" }}kind: Extra\n---\n{{ end }}kind: {{ .NAME }}",
+			options: []BuilderOption{WithEngine(EngineGoTemplate)},
+			expected: []Evaluation{
+				{Manifest: "kind: foo", Arguments: map[string]string{"NAME": "foo"}, Index: 0},
+				{Manifest: "kind: Extra", Arguments: map[string]string{"NAME": "bar"}, Index: 0},
+				{Manifest: "kind: bar", Arguments: map[string]string{"NAME": "bar"}, Index: 1},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := combination.NewStream(
+				combination.WithArgs(map[string][]string{"NAME": {"foo", "bar"}}),
+				combination.WithSolveAhead(),
+			)
+			b, err := NewBuilder(strings.NewReader(tt.file), stream, tt.options...)
+			require.NoError(t, err)
+
+			evaluations, err := b.Evaluate(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, evaluations)
+		})
+	}
+
+	evaluation := Evaluation{Manifest: "kind: foo"}
+	require.Equal(t, "sha256:438fe9d2bba3a9989fe3e5eba9939333dc6c8ddbea92bc6c52f6fc5b83e48784", evaluation.Hash())
+}
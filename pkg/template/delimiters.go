@@ -0,0 +1,75 @@
+package template
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Delimiters mark the start and end of a parameter within a template, e.g. ${{ and }}.
+// When a template has delimiters, only delimited parameters are replaced, so parameter
+// names can't collide with the rest of the template.
+type Delimiters struct {
+	Left  string
+	Right string
+}
+
+// token is a piece of a tokenized string, either literal text or a reference to a parameter
+type token struct {
+	text      string   // the literal text, or the whole reference including its delimiters
+	parameter string   // the name of the parameter referenced, empty for literal text
+	pipeline  []string // the transforms applied to the parameter's value, e.g. dns1123 or truncate:63
+}
+
+// tokenize splits s into literal text and the parameters referenced within it. Whitespace
+// around a parameter's name is ignored, so ${{ NAME }} and ${{NAME}} reference the same
+// parameter. A reference may pipe the parameter through transforms, e.g. ${{ NAME | dns1123 }}.
+// Unterminated or empty references are treated as literal text.
+func (d Delimiters) tokenize(s string) []token {
+	var tokens []token
+	for s != "" {
+		start := strings.Index(s, d.Left)
+		if start < 0 {
+			break
+		}
+		end := strings.Index(s[start+len(d.Left):], d.Right)
+		if end < 0 {
+			break
+		}
+		end += start + len(d.Left)
+
+		name, pipeline := splitPipeline(s[start+len(d.Left) : end])
+		if name == "" {
+			tokens = append(tokens, token{text: s[:end+len(d.Right)]})
+		} else {
+			if start > 0 {
+				tokens = append(tokens, token{text: s[:start]})
+			}
+			tokens = append(tokens, token{text: s[start : end+len(d.Right)], parameter: name, pipeline: pipeline})
+		}
+		s = s[end+len(d.Right):]
+	}
+	if s != "" {
+		tokens = append(tokens, token{text: s})
+	}
+	return tokens
+}
+
+// render joins the tokens back together, replacing each reference to a parameter of the
+// combination with its transformed value. References to any other parameter are left as they are.
+func render(tokens []token, combo map[string]string) (string, error) {
+	var b strings.Builder
+	for _, t := range tokens {
+		value, ok := combo[t.parameter]
+		if !ok || t.parameter == "" {
+			b.WriteString(t.text)
+			continue
+		}
+
+		value, err := applyPipeline(value, t.pipeline)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", t.text, err)
+		}
+		b.WriteString(value)
+	}
+	return b.String(), nil
+}
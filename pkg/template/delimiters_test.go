@@ -0,0 +1,70 @@
+package template
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenize(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		delimiters Delimiters
+		input      string
+		combo      map[string]string
+		expected   []token
+		rendered   string
+	}{
+		{
+			name:       "finds delimited parameters",
+			delimiters: Delimiters{Left: "${{", Right: "}}"},
+			input:      "name: ${{ NAME }}-${{NAMESPACE}}",
+			combo:      map[string]string{"NAME": "foo", "NAMESPACE": "bar"},
+			expected: []token{
+				{text: "name: "},
+				{text: "${{ NAME }}", parameter: "NAME"},
+				{text: "-"},
+				{text: "${{NAMESPACE}}", parameter: "NAMESPACE"},
+			},
+			rendered: "name: foo-bar",
+		},
+		{
+			name:       "ignores bare parameters",
+			delimiters: Delimiters{Left: "{{", Right: "}}"},
+			input:      "NAMESPACE: {{NAME}}",
+			combo:      map[string]string{"NAME": "foo", "NAMESPACE": "bar"},
+			expected: []token{
+				{text: "NAMESPACE: "},
+				{text: "{{NAME}}", parameter: "NAME"},
+			},
+			rendered: "NAMESPACE: foo",
+		},
+		{
+			name:       "leaves unknown, empty and unterminated references as they are",
+			delimiters: Delimiters{Left: "{{", Right: "}}"},
+			input:      "{{ OTHER }} {{ }} {{NAME",
+			combo:      map[string]string{"NAME": "foo"},
+			expected: []token{
+				{text: "{{ OTHER }}", parameter: "OTHER"},
+				{text: " {{ }}"},
+				{text: " {{NAME"},
+			},
+			rendered: "{{ OTHER }} {{ }} {{NAME",
+		},
+		{
+			name:       "tokenizes an empty string",
+			delimiters: Delimiters{Left: "{{", Right: "}}"},
+			input:      "",
+			expected:   nil,
+			rendered:   "",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens := tt.delimiters.tokenize(tt.input)
+			require.Equal(t, tt.expected, tokens)
+			rendered, err := render(tokens, tt.combo)
+			require.NoError(t, err)
+			require.Equal(t, tt.rendered, rendered)
+		})
+	}
+}
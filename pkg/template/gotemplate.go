@@ -0,0 +1,119 @@
+package template
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	gotemplate "text/template"
+
+	comboErrors "github.com/operator-framework/combo/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// funcs is the curated set of functions available to templates rendered by EngineGoTemplate.
+// Functions taking a string operate on their last argument, so they can be used in pipelines,
+// e.g. {{ .NAME | trimPrefix "feature-" | upper }}.
+var funcs = gotemplate.FuncMap{
+	"default": func(def, value string) string {
+		if value == "" {
+			return def
+		}
+		return value
+	},
+	"required": func(message, value string) (string, error) {
+		if value == "" {
+			return "", errors.New(message)
+		}
+		return value, nil
+	},
+	"upper":      strings.ToUpper,
+	"lower":      strings.ToLower,
+	"dns1123":    func(s string) string { return dns1123(s, 0) },
+	"truncate":   func(n int, s string) string { return truncate(s, n) },
+	"sha256":     func(s string) string { return hash(s, 0) },
+	"trim":       strings.TrimSpace,
+	"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+	"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+	"replace":    func(old, replacement, s string) string { return strings.ReplaceAll(s, old, replacement) },
+	"contains":   func(substr, s string) bool { return strings.Contains(s, substr) },
+	"hasPrefix":  func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+	"hasSuffix":  func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+	"split":      func(sep, s string) []string { return strings.Split(s, sep) },
+	"join":       func(sep string, elems []string) string { return strings.Join(elems, sep) },
+	"list":       func(items ...interface{}) []interface{} { return items },
+	"quote":      strconv.Quote,
+	"squote":     func(s string) string { return "'" + strings.ReplaceAll(s, "'", "''") + "'" },
+	"indent":     indent,
+	"nindent": func(spaces int, s string) (string, error) {
+		indented, err := indent(spaces, s)
+		return "\n" + indented, err
+	},
+	"b64enc": func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) },
+	"b64dec": func(s string) (string, error) {
+		decoded, err := base64.StdEncoding.DecodeString(s)
+		return string(decoded), err
+	},
+	"toJson": func(v interface{}) (string, error) {
+		out, err := json.Marshal(v)
+		return string(out), err
+	},
+	"toYaml": func(v interface{}) (string, error) {
+		out, err := yaml.Marshal(v)
+		return strings.TrimSpace(string(out)), err
+	},
+}
+
+// maxIndent is the largest number of spaces indent accepts, which keeps a template from requesting a huge allocation
+const maxIndent = 256
+
+// indent prefixes every line of s with the given number of spaces, which must be between 0 and maxIndent
+func indent(spaces int, s string) (string, error) {
+	if spaces < 0 || spaces > maxIndent {
+		return "", fmt.Errorf("indent requires between 0 and %d spaces, got %d", maxIndent, spaces)
+	}
+	pad := strings.Repeat(" ", spaces)
+	return pad + strings.ReplaceAll(s, "\n", "\n"+pad), nil
+}
+
+// newGoTemplate parses the whole file as a single text/template, since actions such as range may
+// produce any number of manifests. The file is only split into manifests once it has been executed.
+func newGoTemplate(file io.Reader, delimiters *Delimiters) (template, error) {
+	fileBytes, err := ioutil.ReadAll(file)
+	if err != nil {
+		return template{}, fmt.Errorf("%w: %s", comboErrors.ErrCouldNotReadFile, err.Error())
+	}
+
+	compiled := gotemplate.New("template").Funcs(funcs).Option("missingkey=error")
+	if delimiters != nil {
+		compiled = compiled.Delims(delimiters.Left, delimiters.Right)
+	}
+	if compiled, err = compiled.Parse(string(fileBytes)); err != nil {
+		return template{}, fmt.Errorf("%w: %s", ErrInvalidTemplate, err.Error())
+	}
+
+	return template{compiled: compiled}, nil
+}
+
+// execute renders the template with the combination as its data and adds each resulting manifest
+func (t *template) execute(combo map[string]string) error {
+	var out bytes.Buffer
+	if err := t.compiled.Execute(&out, combo); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidTemplate, err.Error())
+	}
+
+	for i, manifest := range strings.Split(out.String(), "---") {
+		manifest = strings.TrimSpace(manifest)
+		var holder interface{}
+		if err := yaml.Unmarshal([]byte(manifest), &holder); err != nil {
+			return fmt.Errorf("%w: rendered manifest %v: %s", ErrInvalidYAML, i, err.Error())
+		}
+		t.add(manifest, combo, i)
+	}
+	return nil
+}
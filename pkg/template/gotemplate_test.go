@@ -0,0 +1,105 @@
+package template
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoTemplate(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		body       string
+		delimiters *Delimiters
+		combos     []map[string]string
+		expected   []string
+		err        error
+	}{
+		{
+			name: "renders the combination as data",
+			body: `name: {{ .NAME }}
+{{- if eq .ENV "prod" }}
+replicas: 3
+{{- end }}`,
+			combos: []map[string]string{
+				{"NAME": "foo", "ENV": "prod"},
+				{"NAME": "bar", "ENV": "dev"},
+			},
+			expected: []string{"name: foo\nreplicas: 3", "name: bar"},
+		},
+		{
+			name: "renders any number of manifests",
+			body: `{{- range split "," .NAMESPACES }}
+---
+namespace: {{ . }}
+{{- end }}`,
+			combos:   []map[string]string{{"NAMESPACES": "foo,bar"}, {"NAMESPACES": "bar,baz"}},
+			expected: []string{"namespace: foo", "namespace: bar", "namespace: baz"},
+		},
+		{
+			name:     "provides the curated functions",
+			body:     `name: {{ index . "NAME" | default "baz" | trimPrefix "feature-" | upper | quote }}`,
+			combos:   []map[string]string{{"NAME": "feature-foo"}, {"OTHER": "bar"}},
+			expected: []string{`name: "FOO"`, `name: "BAZ"`},
+		},
+		{
+			name:     "provides the parameter transforms",
+			body:     `name: {{ .GROUP | dns1123 | truncate 10 }}-{{ .GROUP | sha256 | truncate 8 }}`,
+			combos:   []map[string]string{{"GROUP": "system:serviceaccounts:ci"}},
+			expected: []string{"name: system-ser-585b404e"},
+		},
+		{
+			name:       "uses the delimiters",
+			body:       `name: <% .NAME %>-{{ .NAME }}`,
+			delimiters: &Delimiters{Left: "<%", Right: "%>"},
+			combos:     []map[string]string{{"NAME": "foo"}},
+			expected:   []string{"name: foo-{{ .NAME }}"},
+		},
+		{
+			name:   "fails on a missing parameter",
+			body:   `name: {{ .NAME }}`,
+			combos: []map[string]string{{"OTHER": "foo"}},
+			err:    ErrInvalidTemplate,
+		},
+		{
+			name:   "fails on too large an indent",
+			body:   "name: {{ .NAME | nindent 100000000 }}",
+			combos: []map[string]string{{"NAME": "foo"}},
+			err:    ErrInvalidTemplate,
+		},
+		{
+			name:   "fails on a negative indent",
+			body:   "name: {{ .NAME | indent -1 }}",
+			combos: []map[string]string{{"NAME": "foo"}},
+			err:    ErrInvalidTemplate,
+		},
+		{
+			name:   "fails on invalid rendered yaml",
+			body:   "name: {{ .NAME }}",
+			combos: []map[string]string{{"NAME": "foo: bar"}},
+			err:    ErrInvalidYAML,
+		},
+		{
+			name: "fails on an invalid template",
+			body: `name: {{ .NAME`,
+			err:  ErrInvalidTemplate,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			compiled, err := newGoTemplate(strings.NewReader(tt.body), tt.delimiters)
+			for _, combo := range tt.combos {
+				if err != nil {
+					break
+				}
+				err = compiled.with(combo)
+			}
+			require.ErrorIs(t, err, tt.err)
+			if tt.err != nil {
+				return
+			}
+
+			require.Equal(t, tt.expected, compiled.processedManifests)
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package template
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template/parse"
+
+	comboErrors "github.com/operator-framework/combo/pkg/errors"
+)
+
+// Inspection describes the body of a template before it is evaluated
+type Inspection struct {
+	// Manifests is the number of manifests within the body. Since the GoTemplate engine
+	// may render any number of manifests, it only counts those written out in the body.
+	Manifests int
+
+	// Parameters lists the parameters referenced within the body in lexical order
+	Parameters []string
+}
+
+// Inspect validates the body of a template, evaluated as the options describe, and finds the parameters
+// referenced within it. With delimiters or the GoTemplate engine, every referenced parameter is found.
+// Otherwise parameters can't be told apart from the rest of the body, so only the declared parameters
+// found within it are reported.
+func Inspect(file io.Reader, declared []string, options ...BuilderOption) (Inspection, error) {
+	body, err := ioutil.ReadAll(file)
+	if err != nil {
+		return Inspection{}, fmt.Errorf("%w: %s", comboErrors.ErrCouldNotReadFile, err.Error())
+	}
+
+	t, err := newBuilder(options...).compile(strings.NewReader(string(body)))
+	if err != nil {
+		return Inspection{}, err
+	}
+
+	found := map[string]struct{}{}
+	var inspection Inspection
+	switch {
+	case t.compiled != nil:
+		for _, manifest := range strings.Split(string(body), "---") {
+			if strings.TrimSpace(manifest) != "" {
+				inspection.Manifests++
+			}
+		}
+		for _, defined := range t.compiled.Templates() {
+			if defined.Tree != nil {
+				findFields(defined.Tree.Root, true, found)
+			}
+		}
+	case t.delimiters != nil:
+		inspection.Manifests = len(t.manifests)
+		for i := range t.manifests {
+			for _, token := range t.tokenized(i) {
+				if token.parameter != "" {
+					found[token.parameter] = struct{}{}
+				}
+			}
+		}
+	default:
+		inspection.Manifests = len(t.manifests)
+		for _, key := range declared {
+			expr := regexp.QuoteMeta(key) + `\b`
+			if t.substitution == SubstitutionYAML {
+				expr = wordPattern(key)
+			}
+			for _, manifest := range t.manifests {
+				if t.pattern(expr, expr).MatchString(manifest) {
+					found[key] = struct{}{}
+					break
+				}
+			}
+		}
+	}
+
+	for parameter := range found {
+		inspection.Parameters = append(inspection.Parameters, parameter)
+	}
+	sort.Strings(inspection.Parameters)
+	return inspection, nil
+}
+
+// Undeclared returns the parameters found within the body that aren't declared, in lexical order
+func (i Inspection) Undeclared(declared []string) []string {
+	return difference(i.Parameters, declared)
+}
+
+// Unused returns the declared parameters that weren't found within the body, in the order they were declared
+func (i Inspection) Unused(declared []string) []string {
+	return difference(declared, i.Parameters)
+}
+
+// difference returns the values of a that aren't in b, in the order of a
+func difference(a, b []string) []string {
+	found := make(map[string]struct{}, len(b))
+	for _, value := range b {
+		found[value] = struct{}{}
+	}
+
+	var diff []string
+	for _, value := range a {
+		if _, ok := found[value]; !ok {
+			diff = append(diff, value)
+		}
+	}
+	return diff
+}
+
+// findFields walks a text/template parse tree for the fields of the combination it references, i.e.
+// {{ .NAME }}, {{ $.NAME }} and {{ index . "NAME" }}. Dot only refers to the combination when isData
+// is set, since range and with change it.
+func findFields(node parse.Node, isData bool, found map[string]struct{}) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			findFields(child, isData, found)
+		}
+	case *parse.ActionNode:
+		findFields(n.Pipe, isData, found)
+	case *parse.TemplateNode:
+		findFields(n.Pipe, isData, found)
+	case *parse.IfNode:
+		findFields(n.Pipe, isData, found)
+		findFields(n.List, isData, found)
+		findFields(n.ElseList, isData, found)
+	case *parse.RangeNode:
+		findFields(n.Pipe, isData, found)
+		findFields(n.List, false, found)
+		findFields(n.ElseList, isData, found)
+	case *parse.WithNode:
+		findFields(n.Pipe, isData, found)
+		findFields(n.List, false, found)
+		findFields(n.ElseList, isData, found)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			findFields(cmd, isData, found)
+		}
+	case *parse.CommandNode:
+		if len(n.Args) == 3 {
+			if fn, ok := n.Args[0].(*parse.IdentifierNode); ok && fn.Ident == "index" && refersToData(n.Args[1], isData) {
+				if key, ok := n.Args[2].(*parse.StringNode); ok {
+					found[key.Text] = struct{}{}
+				}
+			}
+		}
+		for _, arg := range n.Args {
+			findFields(arg, isData, found)
+		}
+	case *parse.FieldNode:
+		if isData {
+			found[n.Ident[0]] = struct{}{}
+		}
+	case *parse.VariableNode:
+		if len(n.Ident) > 1 && n.Ident[0] == "$" {
+			found[n.Ident[1]] = struct{}{}
+		}
+	case *parse.ChainNode:
+		findFields(n.Node, isData, found)
+	}
+}
+
+// refersToData determines whether the node is the combination itself, i.e. $ or a dot referring to it
+func refersToData(node parse.Node, isData bool) bool {
+	switch n := node.(type) {
+	case *parse.DotNode:
+		return isData
+	case *parse.VariableNode:
+		return len(n.Ident) == 1 && n.Ident[0] == "$"
+	}
+	return false
+}
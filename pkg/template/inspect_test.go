@@ -0,0 +1,87 @@
+package template
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInspect(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		body     string
+		declared []string
+		options  []BuilderOption
+		expected Inspection
+		err      error
+	}{
+		{
+			name:     "finds the declared parameters in the body",
+			body:     "name: NAME\n---\nnamespace: NAMESPACE_SUFFIX",
+			declared: []string{"NAMESPACE", "NAME", "OTHER"},
+			expected: Inspection{Manifests: 2, Parameters: []string{"NAME"}},
+		},
+		{
+			name:     "finds the declared parameters as whole words with yaml substitution",
+			body:     "name: NAME\nnamespace: MY_NAMESPACE",
+			declared: []string{"NAME", "NAMESPACE"},
+			options:  []BuilderOption{WithSubstitution(SubstitutionYAML)},
+			expected: Inspection{Manifests: 1, Parameters: []string{"NAME"}},
+		},
+		{
+			name:     "finds every delimited parameter",
+			body:     "name: ${{NAME|dns1123}}\n---\nnamespace: ${{ NAMESPACE }}\nother: OTHER",
+			declared: []string{"NAME", "OTHER"},
+			options:  []BuilderOption{WithDelimiters("${{", "}}")},
+			expected: Inspection{Manifests: 2, Parameters: []string{"NAME", "NAMESPACE"}},
+		},
+		{
+			name: "finds the fields referenced by a go template",
+			body: `name: {{ .NAME }}
+{{- range split "," .NAMESPACES }}
+---
+namespace: {{ . }}-{{ .Ignored }}-{{ $.SUFFIX }}
+{{- end }}
+other: {{ index . "OTHER" | default "foo" }}`,
+			options:  []BuilderOption{WithEngine(EngineGoTemplate)},
+			expected: Inspection{Manifests: 2, Parameters: []string{"NAME", "NAMESPACES", "OTHER", "SUFFIX"}},
+		},
+		{
+			name:     "matches declared parameters that aren't identifiers literally",
+			body:     "name: FOO(\nother: BAR\nlast: BAZ",
+			declared: []string{"FOO(", "B.R", "BA[Z]"},
+			expected: Inspection{Manifests: 1},
+		},
+		{
+			name: "fails on invalid yaml",
+			body: "name: [NAME",
+			err:  ErrInvalidYAML,
+		},
+		{
+			name:    "fails on an invalid go template",
+			body:    "name: {{ .NAME",
+			options: []BuilderOption{WithEngine(EngineGoTemplate)},
+			err:     ErrInvalidTemplate,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			inspection, err := Inspect(strings.NewReader(tt.body), tt.declared, tt.options...)
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, inspection)
+		})
+	}
+}
+
+func TestInspectionDifferences(t *testing.T) {
+	inspection := Inspection{Parameters: []string{"NAME", "NAMESPACE", "NICKNAME"}}
+	declared := []string{"REPLICAS", "NAME", "NAMESPACE", "GROUP"}
+
+	require.Equal(t, []string{"NICKNAME"}, inspection.Undeclared(declared))
+	require.Equal(t, []string{"REPLICAS", "GROUP"}, inspection.Unused(declared))
+	require.Nil(t, inspection.Undeclared(inspection.Parameters))
+}
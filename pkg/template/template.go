@@ -1,26 +1,88 @@
 package template
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"regexp"
+	"sort"
 	"strings"
+	gotemplate "text/template"
 
 	comboErrors "github.com/operator-framework/combo/pkg/errors"
 	"gopkg.in/yaml.v3"
 )
 
 var (
-	ErrInvalidYAML = errors.New("invalid yaml")
+	ErrInvalidYAML         = errors.New("invalid yaml")
+	ErrInvalidSubstitution = errors.New("invalid substitution")
+	ErrInvalidDelimiters   = errors.New("invalid delimiters")
+	ErrInvalidEngine       = errors.New("invalid engine")
+	ErrInvalidTemplate     = errors.New("invalid template")
 )
 
+// Engine determines how a template is evaluated with each combination
+type Engine string
+
+const (
+	// EngineReplace replaces each parameter of the template with its value, see Substitution
+	EngineReplace Engine = "Replace"
+
+	// EngineGoTemplate renders the template with text/template, using the combination as its data
+	EngineGoTemplate Engine = "GoTemplate"
+)
+
+// ParseEngine returns the engine with the given case-insensitive name, which is
+// either replace or gotemplate. Replace is returned if no name is given.
+func ParseEngine(name string) (Engine, error) {
+	switch strings.ToLower(name) {
+	case "", "replace":
+		return EngineReplace, nil
+	case "gotemplate":
+		return EngineGoTemplate, nil
+	default:
+		return "", fmt.Errorf("%w: unknown engine %q", ErrInvalidEngine, name)
+	}
+}
+
+// Substitution determines how parameters are replaced within a template's manifests
+type Substitution string
+
+const (
+	// SubstitutionText replaces parameters anywhere in the raw text of each manifest
+	SubstitutionText Substitution = "Text"
+
+	// SubstitutionYAML parses each manifest and only replaces whole words within its scalar
+	// keys and values, so comments are left untouched and values are quoted as needed.
+	SubstitutionYAML Substitution = "YAML"
+)
+
+// ParseSubstitution returns the substitution with the given case-insensitive name,
+// which is either text or yaml. Text is returned if no name is given.
+func ParseSubstitution(name string) (Substitution, error) {
+	switch strings.ToLower(name) {
+	case "", "text":
+		return SubstitutionText, nil
+	case "yaml":
+		return SubstitutionYAML, nil
+	default:
+		return "", fmt.Errorf("%w: unknown substitution %q", ErrInvalidSubstitution, name)
+	}
+}
+
 // template contains an array of manifests that can be
 // interacted with with its various functions.
 type template struct {
 	manifests          []string
 	processedManifests []string
+	evaluations        []Evaluation // what produced each of the processed manifests
+	substitution       Substitution
+	delimiters         *Delimiters               // if set, only parameters enclosed by the delimiters are replaced
+	tokens             [][]token                 // the tokens of each manifest, when using delimiters
+	patterns           map[string]*regexp.Regexp // compiled patterns used to find parameters without delimiters
+	compiled           *gotemplate.Template      // the whole template, when rendered by EngineGoTemplate
 }
 
 // validateFile is a simple wrapper to ensure the manifests we're using are valid YAML
@@ -58,6 +120,15 @@ func newTemplate(file io.Reader) (template, error) {
 	return constructedTemplate, nil
 }
 
+// add adds the manifest evaluated from the manifest at the given index with the combination,
+// if it isn't empty and doesn't already exist in the template
+func (t *template) add(manifest string, combo map[string]string, index int) {
+	if manifest != "" && !t.has(manifest) {
+		t.processedManifests = append(t.processedManifests, manifest)
+		t.evaluations = append(t.evaluations, Evaluation{Manifest: manifest, Arguments: combo, Index: index})
+	}
+}
+
 // has determines if any of the manifests for the template
 // contains the specified string.
 func (t *template) has(searchManifest string) bool {
@@ -70,17 +141,189 @@ func (t *template) has(searchManifest string) bool {
 }
 
 // with builds the template manifests with the combination set specified
-func (t *template) with(combo map[string]string) {
+func (t *template) with(combo map[string]string) error {
+	if t.compiled != nil {
+		return t.execute(combo)
+	}
+
+	keys := replacementOrder(combo)
+
 	// For each manifest in the template evaluate the current combination set
-	for _, manifest := range t.manifests {
-		for key, val := range combo {
-			manifest = regexp.MustCompile(key+`\b`).ReplaceAllString(manifest, val)
+	for i, manifest := range t.manifests {
+		switch {
+		case t.substitution == SubstitutionYAML:
+			var err error
+			if manifest, err = t.substituteYAML(manifest, combo, keys); err != nil {
+				return fmt.Errorf("manifest %v: %w", i, err)
+			}
+		case t.delimiters != nil:
+			var err error
+			if manifest, err = render(t.tokenized(i), combo); err != nil {
+				return fmt.Errorf("manifest %v: %w", i, err)
+			}
+		default:
+			for _, key := range keys {
+				var err error
+				if manifest, err = replaceReferences(t.pattern(key, regexp.QuoteMeta(key)+`\b`+pipelineGroup), manifest, func(_ string, pipeline []string) (string, error) {
+					return applyPipeline(combo[key], pipeline)
+				}); err != nil {
+					return fmt.Errorf("manifest %v: %w", i, err)
+				}
+			}
+		}
+
+		t.add(manifest, combo, i)
+	}
+	return nil
+}
+
+// tokenized returns the tokens of the manifest at index i, tokenizing every manifest on the first call
+func (t *template) tokenized(i int) []token {
+	if t.tokens == nil {
+		t.tokens = make([][]token, len(t.manifests))
+		for x, manifest := range t.manifests {
+			t.tokens[x] = t.delimiters.tokenize(manifest)
+		}
+	}
+	return t.tokens[i]
+}
+
+// pattern returns the compiled expr, compiling it only the first time it's requested under the given name
+func (t *template) pattern(name, expr string) *regexp.Regexp {
+	if t.patterns == nil {
+		t.patterns = map[string]*regexp.Regexp{}
+	}
+	if _, ok := t.patterns[name]; !ok {
+		t.patterns[name] = regexp.MustCompile(expr)
+	}
+	return t.patterns[name]
+}
+
+// substituteYAML parses the manifest and replaces the keys in every scalar of it in a single pass,
+// so replaced values are never replaced again. Scalars keep their style and are strings, quoted as
+// needed, so a value such as true or 123 doesn't change the type of a field. A scalar with an explicit
+// tag keeps it instead, e.g. replicas: !!int REPLICAS evaluates to a number.
+func (t *template) substituteYAML(manifest string, combo map[string]string, keys []string) (string, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal([]byte(manifest), &document); err != nil {
+		return "", fmt.Errorf("%w: %s", ErrInvalidYAML, err.Error())
+	}
+	if document.Kind == 0 || len(keys) == 0 {
+		return manifest, nil
+	}
+
+	var substituteErr error
+	var substitute func(node *yaml.Node)
+	substitute = func(node *yaml.Node) {
+		for _, child := range node.Content {
+			substitute(child)
+		}
+		if node.Kind != yaml.ScalarNode {
+			return
 		}
 
-		// Add the manifest if it isn't empty and doesn't already exist in the template
-		shouldAdd := manifest != "" && !t.has(manifest)
-		if shouldAdd {
-			t.processedManifests = append(t.processedManifests, manifest)
+		replaced, err := t.substituteScalar(node.Value, combo, keys)
+		if err != nil {
+			substituteErr = err
+			return
+		}
+		if replaced != node.Value {
+			node.Value = replaced
+			if node.Style&yaml.TaggedStyle == 0 {
+				node.Tag = "!!str"
+			}
 		}
 	}
+	substitute(&document)
+	if substituteErr != nil {
+		return "", substituteErr
+	}
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&document); err != nil {
+		return "", fmt.Errorf("%w: %s", ErrInvalidYAML, err.Error())
+	}
+	if err := encoder.Close(); err != nil {
+		return "", fmt.Errorf("%w: %s", ErrInvalidYAML, err.Error())
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// substituteScalar replaces the keys within the value of a scalar
+func (t *template) substituteScalar(value string, combo map[string]string, keys []string) (string, error) {
+	if t.delimiters != nil {
+		return render(t.delimiters.tokenize(value), combo)
+	}
+
+	if replaced, ok := combo[value]; ok {
+		return replaced, nil
+	}
+	patterns := make([]string, 0, len(keys))
+	for _, key := range keys {
+		patterns = append(patterns, wordPattern(key))
+	}
+	expr := `(?:` + strings.Join(patterns, "|") + `)` + pipelineGroup
+	replaced, err := replaceReferences(t.pattern(expr, expr), value, func(key string, pipeline []string) (string, error) {
+		return applyPipeline(combo[key], pipeline)
+	})
+	return replaced, err
+}
+
+// replaceReferences replaces every match of the pattern, which must end in pipelineGroup, with the
+// result of replace. It is given the parameter that was matched along with the transforms following it.
+func replaceReferences(pattern *regexp.Regexp, s string, replace func(parameter string, pipeline []string) (string, error)) (string, error) {
+	group := 2 * pattern.SubexpIndex("pipeline")
+	var b strings.Builder
+	last := 0
+	for _, match := range pattern.FindAllStringSubmatchIndex(s, -1) {
+		b.WriteString(s[last:match[0]])
+
+		_, pipeline := splitPipeline(s[match[group]:match[group+1]])
+		replaced, err := replace(s[match[0]:match[group]], pipeline)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", s[match[0]:match[1]], err)
+		}
+		b.WriteString(replaced)
+		last = match[1]
+	}
+	b.WriteString(s[last:])
+	return b.String(), nil
+}
+
+// wordPattern matches the key as a whole word, i.e. not when it's part of a longer identifier
+func wordPattern(key string) string {
+	pattern := regexp.QuoteMeta(key)
+	if key == "" {
+		return pattern
+	}
+	if isWordChar(key[0]) {
+		pattern = `\b` + pattern
+	}
+	if isWordChar(key[len(key)-1]) {
+		pattern += `\b`
+	}
+	return pattern
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || ('0' <= c && c <= '9') || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+// replacementOrder returns the keys of the combination in the order they should be
+// replaced: longest first, so a key is replaced before any shorter key it ends with,
+// and lexically otherwise so the same combination always evaluates the same way.
+func replacementOrder(combo map[string]string) []string {
+	keys := make([]string, 0, len(combo))
+	for key := range combo {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) > len(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
 }
@@ -139,6 +139,34 @@ func TestWith(t *testing.T) {
 				"testTwo: NAME",
 			},
 		},
+		{
+			name:  "matches keys that aren't identifiers literally",
+			combo: map[string]string{"FOO(": "foo", "B.R": "bar"},
+			template: template{
+				manifests: []string{
+					"testOne: FOO(",
+					"testTwo: BAR",
+				},
+			},
+			expected: []string{
+				"testOne: FOO(",
+				"testTwo: BAR",
+			},
+		},
+		{
+			name:  "replaces longer keys before the shorter keys they end with",
+			combo: map[string]string{"NAME": "baz", "FOO_NAME": "foo"},
+			template: template{
+				manifests: []string{
+					"testOne: FOO_NAME",
+					"testTwo: NAME",
+				},
+			},
+			expected: []string{
+				"testOne: foo",
+				"testTwo: baz",
+			},
+		},
 		{
 			name:  "processes manifests that have no replacements made",
 			combo: map[string]string{"NAMESPACE": "foo", "NAME": "baz"},
@@ -190,15 +218,187 @@ func TestWith(t *testing.T) {
 			template: template{},
 			expected: nil,
 		},
+		{
+			name:  "only replaces whole words within scalars in yaml mode",
+			combo: map[string]string{"NAME": "baz"},
+			template: template{
+				substitution: SubstitutionYAML,
+				manifests: []string{
+					"# NAME\ntestOne: NAME # NAME\ntestTwo: MY_NAME\ntestThree: feature-NAME\nNAME: [NAME]",
+				},
+			},
+			expected: []string{
+				"# NAME\ntestOne: baz # NAME\ntestTwo: MY_NAME\ntestThree: feature-baz\nbaz: [baz]",
+			},
+		},
+		{
+			name:  "quotes values that would break the manifest in yaml mode",
+			combo: map[string]string{"NAME": "foo: #bar", "NAMESPACE": "", "VERSION": "1.22"},
+			template: template{
+				substitution: SubstitutionYAML,
+				manifests: []string{
+					"testOne: NAME\ntestTwo: NAMESPACE\ntestThree: v-VERSION\ntestFour: VERSION\ntestFive: \"VERSION\"",
+				},
+			},
+			expected: []string{
+				"testOne: 'foo: #bar'\ntestTwo: \"\"\ntestThree: v-1.22\ntestFour: \"1.22\"\ntestFive: \"1.22\"",
+			},
+		},
+		{
+			name:  "does not replace a value into another value in yaml mode",
+			combo: map[string]string{"NAME": "NAMESPACE", "NAMESPACE": "foo"},
+			template: template{
+				substitution: SubstitutionYAML,
+				manifests: []string{
+					"testOne: NAME-NAMESPACE",
+				},
+			},
+			expected: []string{
+				"testOne: NAMESPACE-foo",
+			},
+		},
+		{
+			name:  "only replaces delimited parameters",
+			combo: map[string]string{"NAME": "baz", "NAMESPACE": "foo"},
+			template: template{
+				delimiters: &Delimiters{Left: "${{", Right: "}}"},
+				manifests: []string{
+					"NAMESPACE: ${{ NAMESPACE }}\nNAME: ${{NAME}}-${{ OTHER }}",
+				},
+			},
+			expected: []string{
+				"NAMESPACE: foo\nNAME: baz-${{ OTHER }}",
+			},
+		},
+		{
+			name:  "only replaces delimited parameters in yaml mode",
+			combo: map[string]string{"NAME": "foo: #bar", "REPLICAS": "3"},
+			template: template{
+				substitution: SubstitutionYAML,
+				delimiters:   &Delimiters{Left: "${{", Right: "}}"},
+				manifests: []string{
+					"# ${{ NAME }}\nNAME: ${{ NAME }}\nreplicas: !!int ${{ REPLICAS }}\nlabel: \"${{REPLICAS}}\"\ncount: ${{ REPLICAS }}",
+				},
+			},
+			expected: []string{
+				"# ${{ NAME }}\nNAME: 'foo: #bar'\nreplicas: !!int 3\nlabel: \"3\"\ncount: \"3\"",
+			},
+		},
+		{
+			name:  "keeps values that look like other types strings in yaml mode",
+			combo: map[string]string{"NAME": "true", "ID": "123", "EMPTY": ""},
+			template: template{
+				substitution: SubstitutionYAML,
+				manifests: []string{
+					"name: NAME\nid: 'ID'\nempty: EMPTY",
+				},
+			},
+			expected: []string{
+				"name: \"true\"\nid: '123'\nempty: \"\"",
+			},
+		},
+		{
+			name:  "transforms parameters",
+			combo: map[string]string{"GROUP": "system:serviceaccounts:ci"},
+			template: template{
+				manifests: []string{
+					"name: GROUP|dns1123|truncate:14-GROUP|sha256:8\ngroup: GROUP\nscript: echo GROUP|tr",
+				},
+			},
+			expected: []string{
+				"name: system-service-585b404e\ngroup: system:serviceaccounts:ci\nscript: echo system:serviceaccounts:ci|tr",
+			},
+		},
+		{
+			name:  "transforms parameters in yaml mode",
+			combo: map[string]string{"GROUP": "system:serviceaccounts:ci", "REPLICAS": "3"},
+			template: template{
+				substitution: SubstitutionYAML,
+				manifests: []string{
+					"name: GROUP|dns1123\nreplicas: REPLICAS|sha256:1",
+				},
+			},
+			expected: []string{
+				"name: system-serviceaccounts-ci\nreplicas: \"4\"",
+			},
+		},
+		{
+			name:  "transforms delimited parameters",
+			combo: map[string]string{"GROUP": "system:serviceaccounts:ci"},
+			template: template{
+				delimiters: &Delimiters{Left: "${{", Right: "}}"},
+				manifests: []string{
+					"name: ${{ GROUP | dns1123 | upper }}",
+				},
+			},
+			expected: []string{
+				"name: SYSTEM-SERVICEACCOUNTS-CI",
+			},
+		},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
-			tt.template.with(tt.combo)
+			require.NoError(t, tt.template.with(tt.combo))
 
 			require.Equal(t, tt.expected, tt.template.processedManifests)
 		})
 	}
 }
 
+func TestWithInvalidTransform(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		template template
+	}{
+		{
+			name:     "rejects an invalid argument",
+			template: template{manifests: []string{"name: NAME|truncate:0"}},
+		},
+		{
+			name:     "rejects an invalid argument in yaml mode",
+			template: template{substitution: SubstitutionYAML, manifests: []string{"name: NAME|sha256:0"}},
+		},
+		{
+			name:     "rejects an unknown delimited transform",
+			template: template{delimiters: &Delimiters{Left: "{{", Right: "}}"}, manifests: []string{"name: '{{ NAME | reverse }}'"}},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.template.with(map[string]string{"NAME": "foo"})
+			require.ErrorIs(t, err, ErrInvalidTransform)
+		})
+	}
+}
+
+func TestParseSubstitution(t *testing.T) {
+	for _, tt := range []struct {
+		name         string
+		substitution string
+		expected     Substitution
+		err          error
+	}{
+		{
+			name:     "defaults to text",
+			expected: SubstitutionText,
+		},
+		{
+			name:         "parses yaml",
+			substitution: "YAML",
+			expected:     SubstitutionYAML,
+		},
+		{
+			name:         "rejects an unknown substitution",
+			substitution: "json",
+			err:          ErrInvalidSubstitution,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			substitution, err := ParseSubstitution(tt.substitution)
+			require.ErrorIs(t, err, tt.err)
+			require.Equal(t, tt.expected, substitution)
+		})
+	}
+}
+
 func TestValidateFile(t *testing.T) {
 	for _, tt := range []struct {
 		name  string
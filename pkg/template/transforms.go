@@ -0,0 +1,121 @@
+package template
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidTransform = errors.New("invalid transform")
+)
+
+// transform modifies the value of a parameter. Transforms follow a parameter in a pipeline
+// separated by |, each optionally taking a numeric argument after a colon, e.g. NAME|dns1123|sha256:8
+type transform struct {
+	apply    func(value string, n int) string
+	argument argument
+}
+
+type argument int
+
+const (
+	argumentNone argument = iota
+	argumentOptional
+	argumentRequired
+)
+
+var transforms = map[string]transform{
+	// lower converts the value to lower case
+	"lower": {apply: func(value string, _ int) string { return strings.ToLower(value) }},
+
+	// upper converts the value to upper case
+	"upper": {apply: func(value string, _ int) string { return strings.ToUpper(value) }},
+
+	// dns1123 converts the value into a valid DNS-1123 label, as required by the names of most
+	// resources, optionally no longer than the given number of characters
+	"dns1123": {apply: dns1123, argument: argumentOptional},
+
+	// truncate shortens the value to at most the given number of characters
+	"truncate": {apply: truncate, argument: argumentRequired},
+
+	// sha256 hashes the value, optionally keeping only the given number of leading hex characters
+	"sha256": {apply: hash, argument: argumentOptional},
+}
+
+// pipelineGroup captures the pipeline of known transforms following a parameter as the pipeline group
+var pipelineGroup = func() string {
+	names := make([]string, 0, len(transforms))
+	for name := range transforms {
+		names = append(names, regexp.QuoteMeta(name))
+	}
+	sort.Strings(names)
+	return `(?P<pipeline>(?:\|(?:` + strings.Join(names, "|") + `)\b(?::[0-9]+)?)*)`
+}()
+
+var invalidDNS1123 = regexp.MustCompile(`[^a-z0-9]+`)
+
+// dns1123 lowercases the value and replaces every run of other characters with a single hyphen,
+// trimming any hyphens from either end, e.g. system:serviceaccounts:ci becomes system-serviceaccounts-ci
+func dns1123(value string, n int) string {
+	value = strings.Trim(invalidDNS1123.ReplaceAllString(strings.ToLower(value), "-"), "-")
+	return strings.TrimRight(truncate(value, n), "-")
+}
+
+// truncate keeps the first n characters of the value, or all of them if n isn't positive
+func truncate(value string, n int) string {
+	if runes := []rune(value); n > 0 && len(runes) > n {
+		return string(runes[:n])
+	}
+	return value
+}
+
+// hash returns the hex encoded SHA-256 sum of the value, truncated to n characters if n is positive
+func hash(value string, n int) string {
+	sum := sha256.Sum256([]byte(value))
+	return truncate(hex.EncodeToString(sum[:]), n)
+}
+
+// splitPipeline splits a reference to a parameter, e.g. NAME|dns1123|truncate:63,
+// into the name of the parameter and its transforms
+func splitPipeline(reference string) (string, []string) {
+	parts := strings.Split(reference, "|")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	if len(parts) == 1 {
+		return parts[0], nil
+	}
+	return parts[0], parts[1:]
+}
+
+// applyPipeline applies each transform of the pipeline to the value in order
+func applyPipeline(value string, pipeline []string) (string, error) {
+	for _, step := range pipeline {
+		nameArg := strings.SplitN(step, ":", 2)
+		t, ok := transforms[nameArg[0]]
+		if !ok {
+			return "", fmt.Errorf("%w: unknown transform %q", ErrInvalidTransform, nameArg[0])
+		}
+
+		n := 0
+		switch {
+		case len(nameArg) == 2 && t.argument == argumentNone:
+			return "", fmt.Errorf("%w: %s does not take an argument", ErrInvalidTransform, nameArg[0])
+		case len(nameArg) == 1 && t.argument == argumentRequired:
+			return "", fmt.Errorf("%w: %s requires an argument, e.g. %s:8", ErrInvalidTransform, nameArg[0], nameArg[0])
+		case len(nameArg) == 2:
+			var err error
+			if n, err = strconv.Atoi(nameArg[1]); err != nil || n < 1 {
+				return "", fmt.Errorf("%w: the argument of %s must be a positive number, got %q", ErrInvalidTransform, nameArg[0], nameArg[1])
+			}
+		}
+		value = t.apply(value, n)
+	}
+	return value, nil
+}
@@ -0,0 +1,89 @@
+package template
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyPipeline(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		value    string
+		pipeline []string
+		expected string
+		err      error
+	}{
+		{
+			name:     "returns the value without any transforms",
+			value:    "Foo",
+			expected: "Foo",
+		},
+		{
+			name:     "changes the case of the value",
+			value:    "Foo",
+			pipeline: []string{"upper", "lower"},
+			expected: "foo",
+		},
+		{
+			name:     "converts the value into a dns1123 label",
+			value:    "system:serviceaccounts:CI",
+			pipeline: []string{"dns1123"},
+			expected: "system-serviceaccounts-ci",
+		},
+		{
+			name:     "trims hyphens left over from shortening a dns1123 label",
+			value:    "--system:serviceaccounts:ci",
+			pipeline: []string{"dns1123:7"},
+			expected: "system",
+		},
+		{
+			name:     "truncates the value",
+			value:    "system:serviceaccounts:ci",
+			pipeline: []string{"truncate:6"},
+			expected: "system",
+		},
+		{
+			name:     "hashes the value",
+			value:    "system:serviceaccounts:ci",
+			pipeline: []string{"sha256:8"},
+			expected: "585b404e",
+		},
+		{
+			name:     "applies the transforms in order",
+			value:    "system:serviceaccounts:ci",
+			pipeline: []string{"upper", "truncate:6", "sha256"},
+			expected: "d621c1a7169f2ca51bc8674da52e9572178a66a1dde88da24da78fe4951703f9",
+		},
+		{
+			name:     "rejects an unknown transform",
+			value:    "foo",
+			pipeline: []string{"reverse"},
+			err:      ErrInvalidTransform,
+		},
+		{
+			name:     "rejects a missing argument",
+			value:    "foo",
+			pipeline: []string{"truncate"},
+			err:      ErrInvalidTransform,
+		},
+		{
+			name:     "rejects an unexpected argument",
+			value:    "foo",
+			pipeline: []string{"lower:3"},
+			err:      ErrInvalidTransform,
+		},
+		{
+			name:     "rejects an argument that isn't a positive number",
+			value:    "foo",
+			pipeline: []string{"sha256:0"},
+			err:      ErrInvalidTransform,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := applyPipeline(tt.value, tt.pipeline)
+			require.ErrorIs(t, err, tt.err)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
@@ -30,7 +30,7 @@ func (u *Updater) UpdateStatus(fs ...UpdateStatusFunc) {
 	u.updateStatusFuncs = append(u.updateStatusFuncs, fs...)
 }
 
-func (u *Updater) Apply(ctx context.Context, c *v1alpha1.Combination) error {
+func (u *Updater) Apply(ctx context.Context, c v1alpha1.CombinationObject) error {
 	backoff := retry.DefaultRetry
 
 	return retry.RetryOnConflict(backoff, func() error {
@@ -39,7 +39,7 @@ func (u *Updater) Apply(ctx context.Context, c *v1alpha1.Combination) error {
 		}
 		needsStatusUpdate := false
 		for _, f := range u.updateStatusFuncs {
-			needsStatusUpdate = f(&c.Status) || needsStatusUpdate
+			needsStatusUpdate = f(c.GetStatus()) || needsStatusUpdate
 		}
 		if needsStatusUpdate {
 			log.FromContext(ctx).Info("applying status changes")
@@ -70,6 +70,93 @@ func EnsureEvaluations(evaluation []string) UpdateStatusFunc {
 	}
 }
 
+func EnsureEvaluationsOverflow(overflow *v1alpha1.EvaluationsOverflow) UpdateStatusFunc {
+	return func(status *v1alpha1.CombinationStatus) bool {
+		if reflect.DeepEqual(status.EvaluationsOverflow, overflow) {
+			return false
+		}
+		status.EvaluationsOverflow = overflow
+		return true
+	}
+}
+
+func EnsureEvaluationRecords(records []v1alpha1.EvaluationRecord) UpdateStatusFunc {
+	return func(status *v1alpha1.CombinationStatus) bool {
+		if reflect.DeepEqual(status.EvaluationRecords, records) {
+			return false
+		}
+		status.EvaluationRecords = records
+		return true
+	}
+}
+
+func EnsureObservedGeneration(generation int64) UpdateStatusFunc {
+	return func(status *v1alpha1.CombinationStatus) bool {
+		if status.ObservedGeneration == generation {
+			return false
+		}
+		status.ObservedGeneration = generation
+		return true
+	}
+}
+
+// Counts summarizes how many combinations a combination evaluated and what became of the resulting manifests
+type Counts struct {
+	TotalCombinations int
+	RenderedManifests int
+	AppliedManifests  int
+	FailedManifests   int
+}
+
+func EnsureCounts(counts Counts) UpdateStatusFunc {
+	return func(status *v1alpha1.CombinationStatus) bool {
+		current := Counts{
+			TotalCombinations: status.TotalCombinations,
+			RenderedManifests: status.RenderedManifests,
+			AppliedManifests:  status.AppliedManifests,
+			FailedManifests:   status.FailedManifests,
+		}
+		if current == counts {
+			return false
+		}
+		status.TotalCombinations = counts.TotalCombinations
+		status.RenderedManifests = counts.RenderedManifests
+		status.AppliedManifests = counts.AppliedManifests
+		status.FailedManifests = counts.FailedManifests
+		return true
+	}
+}
+
+func EnsureResources(resources []v1alpha1.ResourceStatus) UpdateStatusFunc {
+	return func(status *v1alpha1.CombinationStatus) bool {
+		if reflect.DeepEqual(status.Resources, resources) {
+			return false
+		}
+		status.Resources = resources
+		return true
+	}
+}
+
+func EnsurePlan(plan *v1alpha1.Plan) UpdateStatusFunc {
+	return func(status *v1alpha1.CombinationStatus) bool {
+		if reflect.DeepEqual(status.Plan, plan) {
+			return false
+		}
+		status.Plan = plan
+		return true
+	}
+}
+
+func RemoveCondition(conditionType string) UpdateStatusFunc {
+	return func(status *v1alpha1.CombinationStatus) bool {
+		if meta.FindStatusCondition(status.Conditions, conditionType) == nil {
+			return false
+		}
+		meta.RemoveStatusCondition(&status.Conditions, conditionType)
+		return true
+	}
+}
+
 func conditionsSemanticallyEqual(a, b metav1.Condition) bool {
 	return a.Type == b.Type && a.Status == b.Status && a.Reason == b.Reason && a.Message == b.Message && a.ObservedGeneration == b.ObservedGeneration
 }
@@ -0,0 +1,406 @@
+package values
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/operator-framework/combo/api/v1alpha1"
+)
+
+// Specify which errors this package can return
+var (
+	ErrInvalidSource = errors.New("invalid values source")
+	ErrInvalidValues = errors.New("invalid values")
+	ErrUnresolved    = errors.New("could not resolve arguments")
+	ErrNotSelectable = errors.New("kind is not selectable")
+)
+
+// DefaultSelectableKinds are the kinds of objects arguments may select their values from by default. Selecting a kind
+// watches its objects across the cluster, so kinds that are costly to watch or hold sensitive data aren't selectable.
+var DefaultSelectableKinds = []schema.GroupKind{{Kind: "Namespace"}, {Kind: "ConfigMap"}, {Kind: "ServiceAccount"}}
+
+// Resolve returns the values of each argument keyed by the argument's key, reading the values of
+// arguments with a ValuesFrom source from the cluster. An argument selecting objects has no values
+// when none of them match. Every argument that can't be resolved is reported together in a single error.
+func Resolve(ctx context.Context, reader client.Reader, arguments []v1alpha1.Argument) (map[string][]string, error) {
+	args := make(map[string][]string, len(arguments))
+	var failures []string
+	for _, argument := range arguments {
+		values, err := resolve(ctx, reader, argument)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", argument.Key, err.Error()))
+			continue
+		}
+		args[argument.Key] = values
+	}
+
+	if len(failures) != 0 {
+		return nil, fmt.Errorf("%w: %s", ErrUnresolved, strings.Join(failures, "; "))
+	}
+	return args, nil
+}
+
+// resolve returns the values of a single argument
+func resolve(ctx context.Context, reader client.Reader, argument v1alpha1.Argument) ([]string, error) {
+	source := argument.ValuesFrom
+	if source == nil {
+		return argument.Values, nil
+	}
+	if len(argument.Values) != 0 {
+		return nil, fmt.Errorf("%w: only one of values and valuesFrom may be set", ErrInvalidSource)
+	}
+
+	set := 0
+	for _, isSet := range []bool{source.ConfigMapKeyRef != nil, source.SecretKeyRef != nil, source.NamespaceSelector != nil, source.ObjectSelector != nil} {
+		if isSet {
+			set++
+		}
+	}
+	if set != 1 {
+		return nil, fmt.Errorf("%w: exactly one of configMapKeyRef, secretKeyRef, namespaceSelector and objectSelector must be set", ErrInvalidSource)
+	}
+
+	// Selected objects may come and go, so selecting none of them is valid and leaves the argument without values
+	if selector := selectorFor(source); selector != nil {
+		return selectValues(ctx, reader, *selector)
+	}
+
+	ref := source.ConfigMapKeyRef
+	if ref == nil {
+		ref = source.SecretKeyRef
+	}
+	if ref.Namespace == "" {
+		return nil, fmt.Errorf("%w: the namespace of %s must be set", ErrInvalidSource, ref.Name)
+	}
+
+	var data string
+	var err error
+	if source.ConfigMapKeyRef != nil {
+		data, err = configMapData(ctx, reader, *source.ConfigMapKeyRef)
+	} else {
+		data, err = secretData(ctx, reader, *source.SecretKeyRef)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("%w: the source has no values", ErrInvalidValues)
+	}
+	return values, nil
+}
+
+// configMapData returns the value of the referenced key of a ConfigMap, from either its data or binary data
+func configMapData(ctx context.Context, reader client.Reader, ref v1alpha1.KeyReference) (string, error) {
+	configMap := &corev1.ConfigMap{}
+	if err := reader.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, configMap); err != nil {
+		return "", fmt.Errorf("failed to retrieve %s/%s configmap: %w", ref.Namespace, ref.Name, err)
+	}
+
+	if data, ok := configMap.Data[ref.Key]; ok {
+		return data, nil
+	}
+	if data, ok := configMap.BinaryData[ref.Key]; ok {
+		return string(data), nil
+	}
+	return "", fmt.Errorf("%w: %s/%s configmap has no %s key", ErrInvalidSource, ref.Namespace, ref.Name, ref.Key)
+}
+
+// secretData returns the value of the referenced key of a Secret
+func secretData(ctx context.Context, reader client.Reader, ref v1alpha1.KeyReference) (string, error) {
+	secret := &corev1.Secret{}
+	if err := reader.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, secret); err != nil {
+		return "", fmt.Errorf("failed to retrieve %s/%s secret: %w", ref.Namespace, ref.Name, err)
+	}
+
+	data, ok := secret.Data[ref.Key]
+	if !ok {
+		return "", fmt.Errorf("%w: %s/%s secret has no %s key", ErrInvalidSource, ref.Namespace, ref.Name, ref.Key)
+	}
+	return string(data), nil
+}
+
+// selectorFor returns the object selector of the source, treating a namespace selector as a selector of
+// Namespaces, or nil if the source doesn't select objects
+func selectorFor(source *v1alpha1.ValuesSource) *v1alpha1.ObjectSelector {
+	switch {
+	case source.ObjectSelector != nil:
+		return source.ObjectSelector
+	case source.NamespaceSelector != nil:
+		return &v1alpha1.ObjectSelector{APIVersion: "v1", Kind: "Namespace", Selector: source.NamespaceSelector}
+	}
+	return nil
+}
+
+// selectValues lists the objects matching the selector and returns the sorted, distinct values at their field path
+func selectValues(ctx context.Context, reader client.Reader, selector v1alpha1.ObjectSelector) ([]string, error) {
+	gvk, labelSelector, err := parseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+	if err := reader.List(ctx, list, client.InNamespace(selector.Namespace), client.MatchingLabelsSelector{Selector: labelSelector}); err != nil {
+		return nil, fmt.Errorf("failed to list %s %s: %w", selector.APIVersion, selector.Kind, err)
+	}
+
+	fieldPath := selector.FieldPath
+	if fieldPath == "" {
+		fieldPath = "metadata.name"
+	}
+	path := strings.Split(strings.TrimPrefix(fieldPath, "."), ".")
+
+	values := []string{}
+	found := map[string]struct{}{}
+	for _, item := range list.Items {
+		// Listing a cluster-scoped kind ignores the namespace, so skip its objects rather than selecting them all
+		if selector.Namespace != "" && item.GetNamespace() != selector.Namespace {
+			continue
+		}
+
+		field, ok, err := unstructured.NestedFieldNoCopy(item.Object, path...)
+		if err != nil || !ok {
+			continue
+		}
+
+		var value string
+		switch v := field.(type) {
+		case string:
+			value = v
+		case int64, float64, bool:
+			value = fmt.Sprint(v)
+		default:
+			name := item.GetName()
+			if item.GetNamespace() != "" {
+				name = item.GetNamespace() + "/" + name
+			}
+			return nil, fmt.Errorf("%w: %s of %s %s is not a string, number or boolean", ErrInvalidValues, fieldPath, selector.Kind, name)
+		}
+
+		if _, ok := found[value]; !ok {
+			found[value] = struct{}{}
+			values = append(values, value)
+		}
+	}
+	sort.Strings(values)
+	return values, nil
+}
+
+// parseSelector returns the kind of objects the selector selects and its label selector
+func parseSelector(selector v1alpha1.ObjectSelector) (schema.GroupVersionKind, labels.Selector, error) {
+	gv, err := schema.ParseGroupVersion(selector.APIVersion)
+	if err != nil {
+		return schema.GroupVersionKind{}, nil, fmt.Errorf("%w: %s", ErrInvalidSource, err.Error())
+	}
+
+	labelSelector := labels.Everything()
+	if selector.Selector != nil {
+		if labelSelector, err = metav1.LabelSelectorAsSelector(selector.Selector); err != nil {
+			return schema.GroupVersionKind{}, nil, fmt.Errorf("%w: %s", ErrInvalidSource, err.Error())
+		}
+	}
+	return gv.WithKind(selector.Kind), labelSelector, nil
+}
+
+// Confine returns a copy of the arguments whose sources may only read values from objects within the given
+// namespace, as is the case for the arguments of a NamespacedCombination. Key references and object selectors
+// without a namespace default to it, while sources referring to other namespaces or selecting namespaces are
+// rejected. Every argument that can't be confined is reported together in a single error.
+func Confine(arguments []v1alpha1.Argument, namespace string) ([]v1alpha1.Argument, error) {
+	confined := make([]v1alpha1.Argument, 0, len(arguments))
+	var failures []string
+	for _, argument := range arguments {
+		argument = *argument.DeepCopy()
+		if err := confine(argument.ValuesFrom, namespace); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", argument.Key, err.Error()))
+			continue
+		}
+		confined = append(confined, argument)
+	}
+
+	if len(failures) != 0 {
+		return nil, fmt.Errorf("%w: %s", ErrUnresolved, strings.Join(failures, "; "))
+	}
+	return confined, nil
+}
+
+// confine restricts a single source to the given namespace
+func confine(source *v1alpha1.ValuesSource, namespace string) error {
+	if source == nil {
+		return nil
+	}
+	if source.NamespaceSelector != nil {
+		return fmt.Errorf("%w: namespaceSelector may only be used by cluster-scoped combinations", ErrInvalidSource)
+	}
+
+	for _, ns := range []*string{keyNamespace(source.ConfigMapKeyRef), keyNamespace(source.SecretKeyRef), selectorNamespace(source.ObjectSelector)} {
+		if ns == nil {
+			continue
+		}
+		if *ns != "" && *ns != namespace {
+			return fmt.Errorf("%w: the %s namespace is outside of the combination's %s namespace", ErrInvalidSource, *ns, namespace)
+		}
+		*ns = namespace
+	}
+	return nil
+}
+
+// keyNamespace returns a pointer to the namespace of the reference, if any
+func keyNamespace(ref *v1alpha1.KeyReference) *string {
+	if ref == nil {
+		return nil
+	}
+	return &ref.Namespace
+}
+
+// selectorNamespace returns a pointer to the namespace of the selector, if any
+func selectorNamespace(selector *v1alpha1.ObjectSelector) *string {
+	if selector == nil {
+		return nil
+	}
+	return &selector.Namespace
+}
+
+// Kinds returns the distinct kinds of objects the arguments select their values from
+func Kinds(arguments []v1alpha1.Argument) []schema.GroupVersionKind {
+	var kinds []schema.GroupVersionKind
+	found := map[schema.GroupVersionKind]struct{}{}
+	for _, argument := range arguments {
+		if argument.ValuesFrom == nil {
+			continue
+		}
+		selector := selectorFor(argument.ValuesFrom)
+		if selector == nil {
+			continue
+		}
+		gvk, _, err := parseSelector(*selector)
+		if err != nil {
+			continue
+		}
+		if _, ok := found[gvk]; !ok {
+			found[gvk] = struct{}{}
+			kinds = append(kinds, gvk)
+		}
+	}
+	return kinds
+}
+
+// Restrict ensures the arguments only select their values from objects of the given kinds, regardless of their
+// version, including the Namespaces selected by a namespace selector. Every argument selecting another kind is
+// reported together in a single error.
+func Restrict(arguments []v1alpha1.Argument, kinds []schema.GroupKind) error {
+	selectable := make(map[schema.GroupKind]struct{}, len(kinds))
+	for _, kind := range kinds {
+		selectable[kind] = struct{}{}
+	}
+
+	var failures []string
+	for _, argument := range arguments {
+		if argument.ValuesFrom == nil {
+			continue
+		}
+		selector := selectorFor(argument.ValuesFrom)
+		if selector == nil {
+			continue
+		}
+		gvk, _, err := parseSelector(*selector)
+		if err != nil {
+			continue
+		}
+		if _, ok := selectable[gvk.GroupKind()]; !ok {
+			failures = append(failures, fmt.Sprintf("%s: %s", argument.Key, gvk.GroupKind().String()))
+		}
+	}
+
+	if len(failures) != 0 {
+		return fmt.Errorf("%w: %s", ErrNotSelectable, strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// Selects determines whether any of the arguments selects its values from the object, i.e. whether the
+// object is of the selected kind, within the selected namespace and matches the label selector
+func Selects(arguments []v1alpha1.Argument, object client.Object) bool {
+	gvk := object.GetObjectKind().GroupVersionKind()
+	for _, argument := range arguments {
+		if argument.ValuesFrom == nil {
+			continue
+		}
+		selector := selectorFor(argument.ValuesFrom)
+		if selector == nil || (selector.Namespace != "" && selector.Namespace != object.GetNamespace()) {
+			continue
+		}
+		selectedKind, labelSelector, err := parseSelector(*selector)
+		if err != nil || selectedKind != gvk {
+			continue
+		}
+		if labelSelector.Matches(labels.Set(object.GetLabels())) {
+			return true
+		}
+	}
+	return false
+}
+
+// Sensitive returns the keys of the arguments sourcing their values from Secrets, whose values shouldn't be
+// revealed anywhere less protected than a Secret, such as the status of a combination
+func Sensitive(arguments []v1alpha1.Argument) map[string]bool {
+	sensitive := map[string]bool{}
+	for _, argument := range arguments {
+		if argument.ValuesFrom != nil && argument.ValuesFrom.SecretKeyRef != nil {
+			sensitive[argument.Key] = true
+		}
+	}
+	return sensitive
+}
+
+// Parse reads values from either a JSON list of strings, e.g. ["foo", "bar"], or from text holding one
+// value per line. Whitespace around each line is trimmed and blank lines are ignored.
+func Parse(data string) ([]string, error) {
+	trimmed := strings.TrimSpace(data)
+	if strings.HasPrefix(trimmed, "[") {
+		var values []string
+		if err := json.Unmarshal([]byte(trimmed), &values); err != nil {
+			return nil, fmt.Errorf("%w: not a JSON list of strings: %s", ErrInvalidValues, err.Error())
+		}
+		return values, nil
+	}
+
+	var values []string
+	for _, line := range strings.Split(trimmed, "\n") {
+		if value := strings.TrimSpace(line); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values, nil
+}
+
+// References determines whether any of the arguments sources its values from the object with the given
+// namespace and name. The reference to compare of each source is selected by ref, e.g. its ConfigMapKeyRef.
+func References(arguments []v1alpha1.Argument, namespace, name string, ref func(*v1alpha1.ValuesSource) *v1alpha1.KeyReference) bool {
+	for _, argument := range arguments {
+		if argument.ValuesFrom == nil {
+			continue
+		}
+		if key := ref(argument.ValuesFrom); key != nil && key.Namespace == namespace && key.Name == name {
+			return true
+		}
+	}
+	return false
+}
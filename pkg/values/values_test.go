@@ -0,0 +1,336 @@
+package values
+
+import (
+	"context"
+	"testing"
+
+	"github.com/operator-framework/combo/api/v1alpha1"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestParse(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		data     string
+		expected []string
+		err      error
+	}{
+		{
+			name:     "parses a value per line",
+			data:     "foo\n  bar \n\nbaz\n",
+			expected: []string{"foo", "bar", "baz"},
+		},
+		{
+			name:     "parses a JSON list",
+			data:     ` ["foo", "bar baz"] `,
+			expected: []string{"foo", "bar baz"},
+		},
+		{
+			name: "parses nothing",
+			data: "\n \n",
+		},
+		{
+			name: "fails on a JSON list of other types",
+			data: `["foo", 1]`,
+			err:  ErrInvalidValues,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := Parse(tt.data)
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, values)
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	cli := fake.NewClientBuilder().WithObjects(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "combo", Name: "tenants"},
+			Data:       map[string]string{"namespaces": "foo\nbar", "empty": ""},
+			BinaryData: map[string][]byte{"groups": []byte(`["admins"]`)},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "combo", Name: "tokens"},
+			Data:       map[string][]byte{"tokens": []byte("abc\ndef")},
+		},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "foo", Labels: map[string]string{"tenant": "true", "team": "a"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "bar", Labels: map[string]string{"tenant": "true", "team": "b"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "baz", Labels: map[string]string{"tenant": "true", "team": "a"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}},
+	).Build()
+
+	configMapKey := func(name, key string) *v1alpha1.ValuesSource {
+		return &v1alpha1.ValuesSource{ConfigMapKeyRef: &v1alpha1.KeyReference{Namespace: "combo", Name: name, Key: key}}
+	}
+
+	for _, tt := range []struct {
+		name      string
+		arguments []v1alpha1.Argument
+		expected  map[string][]string
+		err       string
+	}{
+		{
+			name: "resolves values and sources",
+			arguments: []v1alpha1.Argument{
+				{Key: "NAME", Values: []string{"baz"}},
+				{Key: "NAMESPACE", ValuesFrom: configMapKey("tenants", "namespaces")},
+				{Key: "GROUP", ValuesFrom: configMapKey("tenants", "groups")},
+				{Key: "TOKEN", ValuesFrom: &v1alpha1.ValuesSource{
+					SecretKeyRef: &v1alpha1.KeyReference{Namespace: "combo", Name: "tokens", Key: "tokens"},
+				}},
+			},
+			expected: map[string][]string{
+				"NAME":      {"baz"},
+				"NAMESPACE": {"foo", "bar"},
+				"GROUP":     {"admins"},
+				"TOKEN":     {"abc", "def"},
+			},
+		},
+		{
+			name: "resolves selected objects",
+			arguments: []v1alpha1.Argument{
+				{Key: "NAMESPACE", ValuesFrom: &v1alpha1.ValuesSource{
+					NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tenant": "true"}},
+				}},
+				{Key: "TEAM", ValuesFrom: &v1alpha1.ValuesSource{
+					ObjectSelector: &v1alpha1.ObjectSelector{APIVersion: "v1", Kind: "Namespace", FieldPath: "metadata.labels.team"},
+				}},
+				{Key: "TOKEN", ValuesFrom: &v1alpha1.ValuesSource{
+					ObjectSelector: &v1alpha1.ObjectSelector{APIVersion: "v1", Kind: "Secret", Namespace: "combo"},
+				}},
+				{Key: "NONE", ValuesFrom: &v1alpha1.ValuesSource{
+					NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tenant": "false"}},
+				}},
+			},
+			expected: map[string][]string{
+				"NAMESPACE": {"bar", "baz", "foo"},
+				"TEAM":      {"a", "b"},
+				"TOKEN":     {"tokens"},
+				"NONE":      {},
+			},
+		},
+		{
+			name: "rejects an invalid selector",
+			arguments: []v1alpha1.Argument{
+				{Key: "NAMESPACE", ValuesFrom: &v1alpha1.ValuesSource{
+					NamespaceSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "tenant", Operator: "Is"}}},
+				}},
+			},
+			err: "NAMESPACE: invalid values source",
+		},
+		{
+			name: "rejects fields that are not scalars",
+			arguments: []v1alpha1.Argument{
+				{Key: "LABELS", ValuesFrom: &v1alpha1.ValuesSource{
+					ObjectSelector: &v1alpha1.ObjectSelector{APIVersion: "v1", Kind: "Namespace", FieldPath: "metadata.labels"},
+				}},
+			},
+			err: "metadata.labels of Namespace bar is not a string, number or boolean",
+		},
+		{
+			name: "reports every argument that cannot be resolved",
+			arguments: []v1alpha1.Argument{
+				{Key: "MISSING", ValuesFrom: configMapKey("missing", "namespaces")},
+				{Key: "KEY", ValuesFrom: configMapKey("tenants", "missing")},
+				{Key: "EMPTY", ValuesFrom: configMapKey("tenants", "empty")},
+			},
+			err: `MISSING: failed to retrieve combo/missing configmap: configmaps "missing" not found; ` +
+				"KEY: invalid values source: combo/tenants configmap has no missing key; " +
+				"EMPTY: invalid values: the source has no values",
+		},
+		{
+			name: "rejects both values and a source",
+			arguments: []v1alpha1.Argument{
+				{Key: "NAMESPACE", Values: []string{"foo"}, ValuesFrom: configMapKey("tenants", "namespaces")},
+			},
+			err: "only one of values and valuesFrom may be set",
+		},
+		{
+			name: "rejects a key reference without a namespace",
+			arguments: []v1alpha1.Argument{{Key: "NAMESPACE", ValuesFrom: &v1alpha1.ValuesSource{
+				ConfigMapKeyRef: &v1alpha1.KeyReference{Name: "tenants", Key: "namespaces"},
+			}}},
+			err: "NAMESPACE: invalid values source: the namespace of tenants must be set",
+		},
+		{
+			name:      "rejects an empty source",
+			arguments: []v1alpha1.Argument{{Key: "NAMESPACE", ValuesFrom: &v1alpha1.ValuesSource{}}},
+			err:       "exactly one of configMapKeyRef, secretKeyRef, namespaceSelector and objectSelector must be set",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := Resolve(context.Background(), cli, tt.arguments)
+			if tt.err != "" {
+				require.ErrorIs(t, err, ErrUnresolved)
+				require.Contains(t, err.Error(), tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, args)
+		})
+	}
+}
+
+func TestConfine(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		arguments []v1alpha1.Argument
+		expected  []v1alpha1.Argument
+		err       string
+	}{
+		{
+			name: "defaults sources to the namespace",
+			arguments: []v1alpha1.Argument{
+				{Key: "NAME", Values: []string{"foo"}},
+				{Key: "TENANT", ValuesFrom: &v1alpha1.ValuesSource{
+					ConfigMapKeyRef: &v1alpha1.KeyReference{Name: "tenants", Key: "tenants"},
+				}},
+				{Key: "TOKEN", ValuesFrom: &v1alpha1.ValuesSource{
+					SecretKeyRef: &v1alpha1.KeyReference{Namespace: "team", Name: "tokens", Key: "tokens"},
+				}},
+				{Key: "DEPLOYMENT", ValuesFrom: &v1alpha1.ValuesSource{
+					ObjectSelector: &v1alpha1.ObjectSelector{APIVersion: "apps/v1", Kind: "Deployment"},
+				}},
+			},
+			expected: []v1alpha1.Argument{
+				{Key: "NAME", Values: []string{"foo"}},
+				{Key: "TENANT", ValuesFrom: &v1alpha1.ValuesSource{
+					ConfigMapKeyRef: &v1alpha1.KeyReference{Namespace: "team", Name: "tenants", Key: "tenants"},
+				}},
+				{Key: "TOKEN", ValuesFrom: &v1alpha1.ValuesSource{
+					SecretKeyRef: &v1alpha1.KeyReference{Namespace: "team", Name: "tokens", Key: "tokens"},
+				}},
+				{Key: "DEPLOYMENT", ValuesFrom: &v1alpha1.ValuesSource{
+					ObjectSelector: &v1alpha1.ObjectSelector{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "team"},
+				}},
+			},
+		},
+		{
+			name: "rejects sources in other namespaces",
+			arguments: []v1alpha1.Argument{
+				{Key: "TENANT", ValuesFrom: &v1alpha1.ValuesSource{
+					ConfigMapKeyRef: &v1alpha1.KeyReference{Namespace: "combo", Name: "tenants", Key: "tenants"},
+				}},
+				{Key: "DEPLOYMENT", ValuesFrom: &v1alpha1.ValuesSource{
+					ObjectSelector: &v1alpha1.ObjectSelector{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "kube-system"},
+				}},
+			},
+			err: "TENANT: invalid values source: the combo namespace is outside of the combination's team namespace; " +
+				"DEPLOYMENT: invalid values source: the kube-system namespace is outside of the combination's team namespace",
+		},
+		{
+			name: "rejects namespace selectors",
+			arguments: []v1alpha1.Argument{
+				{Key: "NAMESPACE", ValuesFrom: &v1alpha1.ValuesSource{NamespaceSelector: &metav1.LabelSelector{}}},
+			},
+			err: "NAMESPACE: invalid values source: namespaceSelector may only be used by cluster-scoped combinations",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			original := make([]v1alpha1.Argument, 0, len(tt.arguments))
+			for _, argument := range tt.arguments {
+				original = append(original, *argument.DeepCopy())
+			}
+
+			confined, err := Confine(tt.arguments, "team")
+			require.Equal(t, original, tt.arguments, "the arguments should not be modified")
+			if tt.err != "" {
+				require.ErrorIs(t, err, ErrUnresolved)
+				require.EqualError(t, err, "could not resolve arguments: "+tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, confined)
+		})
+	}
+}
+
+func TestReferences(t *testing.T) {
+	arguments := []v1alpha1.Argument{
+		{Key: "NAME", Values: []string{"foo"}},
+		{Key: "NAMESPACE", ValuesFrom: &v1alpha1.ValuesSource{
+			ConfigMapKeyRef: &v1alpha1.KeyReference{Namespace: "combo", Name: "tenants", Key: "namespaces"},
+		}},
+	}
+	configMapKeyRef := func(source *v1alpha1.ValuesSource) *v1alpha1.KeyReference { return source.ConfigMapKeyRef }
+	secretKeyRef := func(source *v1alpha1.ValuesSource) *v1alpha1.KeyReference { return source.SecretKeyRef }
+
+	require.True(t, References(arguments, "combo", "tenants", configMapKeyRef))
+	require.False(t, References(arguments, "combo", "tenants", secretKeyRef))
+	require.False(t, References(arguments, "default", "tenants", configMapKeyRef))
+}
+
+func TestSensitive(t *testing.T) {
+	arguments := []v1alpha1.Argument{
+		{Key: "NAME", Values: []string{"foo"}},
+		{Key: "NAMESPACE", ValuesFrom: &v1alpha1.ValuesSource{
+			ConfigMapKeyRef: &v1alpha1.KeyReference{Namespace: "combo", Name: "tenants", Key: "namespaces"},
+		}},
+		{Key: "TOKEN", ValuesFrom: &v1alpha1.ValuesSource{
+			SecretKeyRef: &v1alpha1.KeyReference{Namespace: "combo", Name: "tokens", Key: "token"},
+		}},
+	}
+
+	require.Equal(t, map[string]bool{"TOKEN": true}, Sensitive(arguments))
+	require.Empty(t, Sensitive(arguments[:2]))
+}
+
+func TestRestrict(t *testing.T) {
+	arguments := []v1alpha1.Argument{
+		{Key: "NAME", Values: []string{"foo"}},
+		{Key: "NAMESPACE", ValuesFrom: &v1alpha1.ValuesSource{NamespaceSelector: &metav1.LabelSelector{}}},
+		{Key: "TEAM", ValuesFrom: &v1alpha1.ValuesSource{ObjectSelector: &v1alpha1.ObjectSelector{APIVersion: "apps/v1", Kind: "Deployment"}}},
+	}
+
+	require.NoError(t, Restrict(arguments, []schema.GroupKind{{Kind: "Namespace"}, {Group: "apps", Kind: "Deployment"}}))
+	require.NoError(t, Restrict(arguments[:1], nil))
+
+	err := Restrict(arguments, DefaultSelectableKinds)
+	require.ErrorIs(t, err, ErrNotSelectable)
+	require.EqualError(t, err, "kind is not selectable: TEAM: Deployment.apps")
+
+	err = Restrict(arguments, nil)
+	require.EqualError(t, err, "kind is not selectable: NAMESPACE: Namespace; TEAM: Deployment.apps")
+}
+
+func TestSelects(t *testing.T) {
+	arguments := []v1alpha1.Argument{
+		{Key: "NAME", Values: []string{"foo"}},
+		{Key: "NAMESPACE", ValuesFrom: &v1alpha1.ValuesSource{
+			NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tenant": "true"}},
+		}},
+		{Key: "DEPLOYMENT", ValuesFrom: &v1alpha1.ValuesSource{
+			ObjectSelector: &v1alpha1.ObjectSelector{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "combo"},
+		}},
+	}
+	object := func(apiVersion, kind, namespace string, labels map[string]string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion(apiVersion)
+		u.SetKind(kind)
+		u.SetNamespace(namespace)
+		u.SetName("foo")
+		u.SetLabels(labels)
+		return u
+	}
+
+	require.True(t, Selects(arguments, object("v1", "Namespace", "", map[string]string{"tenant": "true"})))
+	require.False(t, Selects(arguments, object("v1", "Namespace", "", map[string]string{"tenant": "false"})))
+	require.True(t, Selects(arguments, object("apps/v1", "Deployment", "combo", nil)))
+	require.False(t, Selects(arguments, object("apps/v1", "Deployment", "default", nil)))
+	require.False(t, Selects(arguments, object("v1", "ConfigMap", "combo", nil)))
+
+	require.Equal(t, []schema.GroupVersionKind{
+		{Version: "v1", Kind: "Namespace"},
+		{Group: "apps", Version: "v1", Kind: "Deployment"},
+	}, Kinds(arguments))
+}
@@ -0,0 +1,127 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/operator-framework/combo/api/v1alpha1"
+	combinationPkg "github.com/operator-framework/combo/pkg/combination"
+	"github.com/operator-framework/combo/pkg/parameter"
+	"github.com/operator-framework/combo/pkg/values"
+)
+
+// combinationValidator rejects combinations with too many combinations of arguments, whose
+// arguments don't match the parameters of their template or select kinds that aren't selectable,
+// or namespaced combinations whose arguments source values from outside of their namespace
+type combinationValidator struct {
+	client.Reader
+	log             logr.Logger
+	maxProductSize  int
+	selectableKinds []schema.GroupKind
+}
+
+func (v *combinationValidator) ValidateCreate(ctx context.Context, obj runtime.Object) error {
+	return v.validate(ctx, obj)
+}
+
+// ValidateUpdate only validates updates that change the spec of a combination that isn't being deleted,
+// so that metadata and status updates, such as removing the controller's finalizer, are never rejected
+func (v *combinationValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) error {
+	oldCombination, oldOk := oldObj.(v1alpha1.CombinationObject)
+	newCombination, newOk := newObj.(v1alpha1.CombinationObject)
+	if oldOk && newOk && (newCombination.GetDeletionTimestamp() != nil || equality.Semantic.DeepEqual(oldCombination.GetSpec(), newCombination.GetSpec())) {
+		return nil
+	}
+	return v.validate(ctx, newObj)
+}
+
+func (v *combinationValidator) ValidateDelete(context.Context, runtime.Object) error {
+	return nil
+}
+
+func (v *combinationValidator) validate(ctx context.Context, obj runtime.Object) error {
+	combination, ok := obj.(v1alpha1.CombinationObject)
+	if !ok {
+		return fmt.Errorf("%w: expected a combination but got %T", ErrInvalidCombination, obj)
+	}
+	spec := combination.GetSpec()
+	v.log.V(1).Info("validating combination", "namespace", combination.GetNamespace(), "name", combination.GetName())
+
+	// Namespaced combinations evaluate templates within their namespace and may only source values from it
+	var template v1alpha1.TemplateObject = &v1alpha1.Template{}
+	if _, namespaced := combination.(*v1alpha1.NamespacedCombination); namespaced {
+		template = &v1alpha1.NamespacedTemplate{}
+		if _, err := values.Confine(spec.Arguments, combination.GetNamespace()); err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidCombination, err.Error())
+		}
+	}
+
+	if err := values.Restrict(spec.Arguments, v.selectableKinds); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidCombination, err.Error())
+	}
+
+	args := placeholderArgs(*spec)
+
+	// A combination without arguments evaluates the template once, leaving nothing to count
+	if v.maxProductSize > 0 && len(args) != 0 {
+		stream := combinationPkg.NewStream(combinationPkg.WithArgs(args), combinationPkg.WithZip(spec.Zip...))
+		if err := stream.Seek(0); err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidCombination, err.Error())
+		}
+		if stream.Len() > v.maxProductSize {
+			return fmt.Errorf("%w: the arguments have %d combinations, more than the maximum of %d", ErrInvalidCombination, stream.Len(), v.maxProductSize)
+		}
+	}
+
+	// The template may be created after the combination, in which case the controller reports it as missing
+	if err := v.Get(ctx, types.NamespacedName{Namespace: combination.GetNamespace(), Name: spec.Template}, template); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to retrieve %s template: %w", spec.Template, err)
+	}
+
+	if err := parameter.Match(template.GetSpec().Parameters, args); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidCombination, err.Error())
+	}
+	return nil
+}
+
+// placeholderArgs returns the values of each argument of the spec. Sourced values are only read during
+// reconciliation, so each source is given a single placeholder value instead, or as many as the other
+// arguments it's zipped with have, so it counts as a single value towards the size of the product.
+func placeholderArgs(spec v1alpha1.CombinationSpec) map[string][]string {
+	args := map[string][]string{}
+	sourced := map[string]bool{}
+	for _, argument := range spec.Arguments {
+		args[argument.Key] = argument.Values
+		if argument.ValuesFrom != nil {
+			args[argument.Key] = []string{argument.Key}
+			sourced[argument.Key] = true
+		}
+	}
+
+	for _, group := range spec.Zip {
+		length := 1
+		for _, key := range group {
+			if values, ok := args[key]; ok && !sourced[key] {
+				length = len(values)
+				break
+			}
+		}
+		for _, key := range group {
+			if sourced[key] {
+				args[key] = make([]string, length)
+			}
+		}
+	}
+	return args
+}
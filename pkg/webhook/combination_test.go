@@ -0,0 +1,291 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/operator-framework/combo/api/v1alpha1"
+	"github.com/operator-framework/combo/pkg/values"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestValidateCombination(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+	cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&v1alpha1.Template{
+		ObjectMeta: metav1.ObjectMeta{Name: "feature"},
+		Spec: v1alpha1.TemplateSpec{
+			Body:       "name: NAME\nnamespace: NAMESPACE",
+			Parameters: []v1alpha1.Parameter{{Name: "NAME"}, {Name: "NAMESPACE"}},
+		},
+	}).Build()
+
+	for _, tt := range []struct {
+		name           string
+		spec           v1alpha1.CombinationSpec
+		maxProductSize int
+		err            string
+	}{
+		{
+			name: "accepts arguments for the template's parameters",
+			spec: v1alpha1.CombinationSpec{
+				Template: "feature",
+				Arguments: []v1alpha1.Argument{
+					{Key: "NAME", Values: []string{"foo", "bar"}},
+					{Key: "NAMESPACE", Values: []string{"baz"}},
+				},
+			},
+		},
+		{
+			name: "accepts a missing template",
+			spec: v1alpha1.CombinationSpec{
+				Template:  "missing",
+				Arguments: []v1alpha1.Argument{{Key: "OTHER", Values: []string{"foo"}}},
+			},
+		},
+		{
+			name: "rejects unknown parameters",
+			spec: v1alpha1.CombinationSpec{
+				Template: "feature",
+				Arguments: []v1alpha1.Argument{
+					{Key: "NAME", Values: []string{"foo"}},
+					{Key: "NAMESPACE", Values: []string{"baz"}},
+					{Key: "NAMESAPCE", Values: []string{"baz"}},
+				},
+			},
+			err: "unknown keys: NAMESAPCE",
+		},
+		{
+			name: "accepts a product no larger than the maximum",
+			spec: v1alpha1.CombinationSpec{
+				Template: "feature",
+				Arguments: []v1alpha1.Argument{
+					{Key: "NAME", Values: []string{"foo", "bar"}},
+					{Key: "NAMESPACE", Values: []string{"baz", "qux"}},
+				},
+			},
+			maxProductSize: 4,
+		},
+		{
+			name: "rejects a product larger than the maximum",
+			spec: v1alpha1.CombinationSpec{
+				Template: "feature",
+				Arguments: []v1alpha1.Argument{
+					{Key: "NAME", Values: []string{"foo", "bar"}},
+					{Key: "NAMESPACE", Values: []string{"baz", "qux", "quux"}},
+				},
+			},
+			maxProductSize: 4,
+			err:            "the arguments have 6 combinations, more than the maximum of 4",
+		},
+		{
+			name: "accepts no arguments with a maximum",
+			spec: v1alpha1.CombinationSpec{
+				Template: "missing",
+			},
+			maxProductSize: 4,
+		},
+		{
+			name: "counts zipped arguments once",
+			spec: v1alpha1.CombinationSpec{
+				Template: "feature",
+				Arguments: []v1alpha1.Argument{
+					{Key: "NAME", Values: []string{"foo", "bar", "baz"}},
+					{Key: "NAMESPACE", Values: []string{"foo", "bar", "baz"}},
+				},
+				Zip: [][]string{{"NAME", "NAMESPACE"}},
+			},
+			maxProductSize: 4,
+		},
+		{
+			name: "counts sourced arguments as a single value",
+			spec: v1alpha1.CombinationSpec{
+				Template: "feature",
+				Arguments: []v1alpha1.Argument{
+					{Key: "NAME", Values: []string{"foo", "bar", "baz", "qux"}},
+					{Key: "NAMESPACE", ValuesFrom: &v1alpha1.ValuesSource{
+						ConfigMapKeyRef: &v1alpha1.KeyReference{Namespace: "combo", Name: "tenants", Key: "namespaces"},
+					}},
+				},
+			},
+			maxProductSize: 4,
+		},
+		{
+			name: "counts sourced arguments as a single value when zipped",
+			spec: v1alpha1.CombinationSpec{
+				Template: "feature",
+				Arguments: []v1alpha1.Argument{
+					{Key: "NAME", Values: []string{"foo", "bar", "baz", "qux"}},
+					{Key: "NAMESPACE", ValuesFrom: &v1alpha1.ValuesSource{
+						ConfigMapKeyRef: &v1alpha1.KeyReference{Namespace: "combo", Name: "tenants", Key: "namespaces"},
+					}},
+				},
+				Zip: [][]string{{"NAMESPACE", "NAME"}},
+			},
+			maxProductSize: 4,
+		},
+		{
+			name: "accepts arguments selecting selectable kinds",
+			spec: v1alpha1.CombinationSpec{
+				Template: "feature",
+				Arguments: []v1alpha1.Argument{
+					{Key: "NAME", Values: []string{"foo"}},
+					{Key: "NAMESPACE", ValuesFrom: &v1alpha1.ValuesSource{NamespaceSelector: &metav1.LabelSelector{}}},
+				},
+			},
+		},
+		{
+			name: "rejects arguments selecting kinds that aren't selectable",
+			spec: v1alpha1.CombinationSpec{
+				Template: "feature",
+				Arguments: []v1alpha1.Argument{
+					{Key: "NAME", ValuesFrom: &v1alpha1.ValuesSource{ObjectSelector: &v1alpha1.ObjectSelector{APIVersion: "v1", Kind: "Secret"}}},
+					{Key: "NAMESPACE", Values: []string{"baz"}},
+				},
+			},
+			err: "kind is not selectable: NAME: Secret",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &combinationValidator{Reader: cli, log: logr.Discard(), maxProductSize: tt.maxProductSize, selectableKinds: values.DefaultSelectableKinds}
+			combination := &v1alpha1.Combination{ObjectMeta: metav1.ObjectMeta{Name: "foo"}, Spec: tt.spec}
+
+			err := v.ValidateCreate(context.Background(), combination)
+			if tt.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, ErrInvalidCombination)
+			require.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestValidateNamespacedCombination(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+	cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&v1alpha1.NamespacedTemplate{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "feature"},
+		Spec: v1alpha1.TemplateSpec{
+			Body:       "name: NAME",
+			Parameters: []v1alpha1.Parameter{{Name: "NAME"}},
+		},
+	}).Build()
+
+	for _, tt := range []struct {
+		name      string
+		namespace string
+		spec      v1alpha1.CombinationSpec
+		err       string
+	}{
+		{
+			name:      "accepts arguments for the template in its namespace",
+			namespace: "team",
+			spec: v1alpha1.CombinationSpec{
+				Template: "feature",
+				Arguments: []v1alpha1.Argument{{Key: "NAME", ValuesFrom: &v1alpha1.ValuesSource{
+					ConfigMapKeyRef: &v1alpha1.KeyReference{Name: "names", Key: "names"},
+				}}},
+			},
+		},
+		{
+			name:      "rejects unknown parameters of the template in its namespace",
+			namespace: "team",
+			spec: v1alpha1.CombinationSpec{
+				Template:  "feature",
+				Arguments: []v1alpha1.Argument{{Key: "OTHER", Values: []string{"foo"}}},
+			},
+			err: "unknown keys: OTHER",
+		},
+		{
+			name:      "ignores templates in other namespaces",
+			namespace: "other",
+			spec: v1alpha1.CombinationSpec{
+				Template:  "feature",
+				Arguments: []v1alpha1.Argument{{Key: "OTHER", Values: []string{"foo"}}},
+			},
+		},
+		{
+			name:      "rejects sources in other namespaces",
+			namespace: "team",
+			spec: v1alpha1.CombinationSpec{
+				Template: "feature",
+				Arguments: []v1alpha1.Argument{{Key: "NAME", ValuesFrom: &v1alpha1.ValuesSource{
+					SecretKeyRef: &v1alpha1.KeyReference{Namespace: "combo", Name: "names", Key: "names"},
+				}}},
+			},
+			err: "the combo namespace is outside of the combination's team namespace",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &combinationValidator{Reader: cli, log: logr.Discard()}
+			combination := &v1alpha1.NamespacedCombination{ObjectMeta: metav1.ObjectMeta{Namespace: tt.namespace, Name: "foo"}, Spec: tt.spec}
+
+			err := v.ValidateCreate(context.Background(), combination)
+			if tt.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, ErrInvalidCombination)
+			require.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestValidateCombinationUpdate(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+	cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&v1alpha1.Template{
+		ObjectMeta: metav1.ObjectMeta{Name: "feature"},
+		Spec: v1alpha1.TemplateSpec{
+			Body:       "name: NAME",
+			Parameters: []v1alpha1.Parameter{{Name: "NAME"}},
+		},
+	}).Build()
+
+	// The combination no longer matches its template, e.g. because the template changed after it was admitted
+	invalid := v1alpha1.CombinationSpec{
+		Template:  "feature",
+		Arguments: []v1alpha1.Argument{{Key: "OTHER", Values: []string{"foo"}}},
+	}
+	now := metav1.Now()
+
+	for _, tt := range []struct {
+		name     string
+		old, new *v1alpha1.Combination
+		err      string
+	}{
+		{
+			name: "accepts updates that don't change the spec",
+			old:  &v1alpha1.Combination{ObjectMeta: metav1.ObjectMeta{Name: "foo"}, Spec: invalid},
+			new:  &v1alpha1.Combination{ObjectMeta: metav1.ObjectMeta{Name: "foo", Finalizers: []string{v1alpha1.CleanupFinalizer}}, Spec: invalid},
+		},
+		{
+			name: "accepts updates of combinations being deleted",
+			old:  &v1alpha1.Combination{ObjectMeta: metav1.ObjectMeta{Name: "foo", Finalizers: []string{v1alpha1.CleanupFinalizer}}, Spec: invalid},
+			new:  &v1alpha1.Combination{ObjectMeta: metav1.ObjectMeta{Name: "foo", DeletionTimestamp: &now}, Spec: invalid},
+		},
+		{
+			name: "rejects updates that change the spec",
+			old:  &v1alpha1.Combination{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
+			new:  &v1alpha1.Combination{ObjectMeta: metav1.ObjectMeta{Name: "foo"}, Spec: invalid},
+			err:  "unknown keys: OTHER",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &combinationValidator{Reader: cli, log: logr.Discard()}
+
+			err := v.ValidateUpdate(context.Background(), tt.old, tt.new)
+			if tt.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, ErrInvalidCombination)
+			require.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
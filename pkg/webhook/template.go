@@ -0,0 +1,86 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/operator-framework/combo/api/v1alpha1"
+	"github.com/operator-framework/combo/pkg/parameter"
+	templatePkg "github.com/operator-framework/combo/pkg/template"
+)
+
+// Specify which errors this package can return
+var (
+	ErrInvalidTemplate    = errors.New("invalid template")
+	ErrInvalidCombination = errors.New("invalid combination")
+)
+
+// templateValidator rejects templates whose parameters aren't well defined, whose body can't be
+// evaluated, or whose body references parameters that aren't declared
+type templateValidator struct {
+	log logr.Logger
+}
+
+func (v *templateValidator) ValidateCreate(_ context.Context, obj runtime.Object) error {
+	return v.validate(obj)
+}
+
+// ValidateUpdate only validates updates that change the spec of a template that isn't being deleted
+func (v *templateValidator) ValidateUpdate(_ context.Context, oldObj, newObj runtime.Object) error {
+	oldTemplate, oldOk := oldObj.(v1alpha1.TemplateObject)
+	newTemplate, newOk := newObj.(v1alpha1.TemplateObject)
+	if oldOk && newOk && (newTemplate.GetDeletionTimestamp() != nil || equality.Semantic.DeepEqual(oldTemplate.GetSpec(), newTemplate.GetSpec())) {
+		return nil
+	}
+	return v.validate(newObj)
+}
+
+func (v *templateValidator) ValidateDelete(context.Context, runtime.Object) error {
+	return nil
+}
+
+func (v *templateValidator) validate(obj runtime.Object) error {
+	template, ok := obj.(v1alpha1.TemplateObject)
+	if !ok {
+		return fmt.Errorf("%w: expected a template but got %T", ErrInvalidTemplate, obj)
+	}
+	v.log.V(1).Info("validating template", "namespace", template.GetNamespace(), "name", template.GetName())
+
+	if err := validateTemplate(*template.GetSpec()); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidTemplate, err.Error())
+	}
+	return nil
+}
+
+// validateTemplate ensures the spec's parameters are well defined and that its body can be evaluated.
+// When parameters are declared, every parameter referenced within the body must be one of them.
+func validateTemplate(spec v1alpha1.TemplateSpec) error {
+	if err := parameter.Validate(spec.Parameters); err != nil {
+		return err
+	}
+
+	options, err := templatePkg.OptionsFor(spec)
+	if err != nil {
+		return err
+	}
+
+	declared := make([]string, 0, len(spec.Parameters))
+	for _, p := range spec.Parameters {
+		declared = append(declared, p.Name)
+	}
+	inspection, err := templatePkg.Inspect(strings.NewReader(spec.Body), declared, options...)
+	if err != nil {
+		return err
+	}
+
+	if undeclared := inspection.Undeclared(declared); len(declared) != 0 && len(undeclared) != 0 {
+		return fmt.Errorf("the body references undeclared parameters: %s", strings.Join(undeclared, ", "))
+	}
+	return nil
+}
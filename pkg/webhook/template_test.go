@@ -0,0 +1,94 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/operator-framework/combo/api/v1alpha1"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateTemplate(t *testing.T) {
+	pointerTo := func(s string) *string { return &s }
+
+	for _, tt := range []struct {
+		name string
+		spec v1alpha1.TemplateSpec
+		err  string
+	}{
+		{
+			name: "accepts a valid template",
+			spec: v1alpha1.TemplateSpec{
+				Body:       "name: NAME\nnamespace: NAMESPACE",
+				Parameters: []v1alpha1.Parameter{{Name: "NAME"}, {Name: "NAMESPACE"}},
+			},
+		},
+		{
+			name: "accepts undeclared parameters when none are declared",
+			spec: v1alpha1.TemplateSpec{
+				Body:       "name: ${{ NAME }}",
+				Delimiters: &v1alpha1.Delimiters{Left: "${{", Right: "}}"},
+			},
+		},
+		{
+			name: "rejects invalid yaml",
+			spec: v1alpha1.TemplateSpec{
+				Body:       "name: [NAME",
+				Parameters: []v1alpha1.Parameter{{Name: "NAME"}},
+			},
+			err: "invalid yaml",
+		},
+		{
+			name: "rejects undeclared parameters",
+			spec: v1alpha1.TemplateSpec{
+				Body:       "name: ${{ NAME }}-${{ SUFFIX }}\nnamespace: ${{ NAMESPACE }}",
+				Parameters: []v1alpha1.Parameter{{Name: "NAME"}, {Name: "NAMESPACE"}},
+				Delimiters: &v1alpha1.Delimiters{Left: "${{", Right: "}}"},
+			},
+			err: "the body references undeclared parameters: SUFFIX",
+		},
+		{
+			name: "rejects fields of a go template that are not declared",
+			spec: v1alpha1.TemplateSpec{
+				Body:       "name: {{ .NAME }}-{{ .SUFFIX }}",
+				Parameters: []v1alpha1.Parameter{{Name: "NAME"}},
+				Engine:     v1alpha1.EngineGoTemplate,
+			},
+			err: "the body references undeclared parameters: SUFFIX",
+		},
+		{
+			name: "rejects parameters that are not well defined",
+			spec: v1alpha1.TemplateSpec{
+				Body:       "replicas: REPLICAS",
+				Parameters: []v1alpha1.Parameter{{Name: "REPLICAS", Type: v1alpha1.ParameterTypeInt, Default: pointerTo("one")}},
+			},
+			err: "REPLICAS has an invalid default",
+		},
+		{
+			name: "rejects parameter names that aren't identifiers",
+			spec: v1alpha1.TemplateSpec{
+				Body:       "name: FOO(\nnamespace: NAMESPACE",
+				Parameters: []v1alpha1.Parameter{{Name: "FOO("}, {Name: "NAMESPACE"}},
+			},
+			err: `"FOO(" is not a valid parameter name`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &templateValidator{log: logr.Discard()}
+			template := &v1alpha1.Template{ObjectMeta: metav1.ObjectMeta{Name: "foo"}, Spec: tt.spec}
+			previous := &v1alpha1.Template{ObjectMeta: metav1.ObjectMeta{Name: "foo"}}
+
+			err := v.ValidateCreate(context.Background(), template)
+			if tt.err == "" {
+				require.NoError(t, err)
+				require.NoError(t, v.ValidateUpdate(context.Background(), previous, template))
+				return
+			}
+			require.ErrorIs(t, err, ErrInvalidTemplate)
+			require.Contains(t, err.Error(), tt.err)
+			require.Error(t, v.ValidateUpdate(context.Background(), previous, template))
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package webhook
+
+import (
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/operator-framework/combo/api/v1alpha1"
+	"github.com/operator-framework/combo/pkg/values"
+)
+
+// The paths the webhook validates each resource at, as referenced by a ValidatingWebhookConfiguration
+const (
+	TemplatePath              = "/validate-combo-io-v1alpha1-template"
+	CombinationPath           = "/validate-combo-io-v1alpha1-combination"
+	NamespacedTemplatePath    = "/validate-combo-io-v1alpha1-namespacedtemplate"
+	NamespacedCombinationPath = "/validate-combo-io-v1alpha1-namespacedcombination"
+)
+
+// Webhook rejects templates and combinations of either scope that could never be evaluated before they are admitted to the cluster
+type Webhook struct {
+	client.Client
+	log             logr.Logger
+	maxProductSize  int
+	selectableKinds []schema.GroupKind
+}
+
+type WebhookOption func(*Webhook)
+
+// NewWebhook constructs and returns a webhook.
+func NewWebhook(cli client.Client, log logr.Logger, options ...WebhookOption) *Webhook {
+	w := &Webhook{
+		Client:          cli,
+		log:             log,
+		selectableKinds: values.DefaultSelectableKinds,
+	}
+	for _, option := range options {
+		option(w)
+	}
+	return w
+}
+
+// WithMaxProductSize rejects combinations whose arguments have more than n combinations in total,
+// i.e. the size of their product regardless of the combination's strategy. Arguments sourced from other objects
+// count as a single value. There's no limit if n isn't positive.
+func WithMaxProductSize(n int) WebhookOption {
+	return func(w *Webhook) {
+		w.maxProductSize = n
+	}
+}
+
+// WithSelectableKinds rejects combinations with arguments selecting their values from objects of any other kind,
+// as the controller refuses to evaluate them. It defaults to values.DefaultSelectableKinds.
+func WithSelectableKinds(kinds ...schema.GroupKind) WebhookOption {
+	return func(w *Webhook) {
+		w.selectableKinds = kinds
+	}
+}
+
+// ManageWith registers the webhook with the given manager's webhook server.
+func (w *Webhook) ManageWith(mgr ctrl.Manager) error {
+	if err := v1alpha1.AddToScheme(mgr.GetScheme()); err != nil {
+		return err
+	}
+
+	for _, template := range []v1alpha1.TemplateObject{&v1alpha1.Template{}, &v1alpha1.NamespacedTemplate{}} {
+		if err := ctrl.NewWebhookManagedBy(mgr).
+			For(template).
+			WithValidator(&templateValidator{log: w.log.WithValues("webhook", "template")}).
+			Complete(); err != nil {
+			return err
+		}
+	}
+
+	for _, combination := range []v1alpha1.CombinationObject{&v1alpha1.Combination{}, &v1alpha1.NamespacedCombination{}} {
+		if err := ctrl.NewWebhookManagedBy(mgr).
+			For(combination).
+			WithValidator(&combinationValidator{
+				Reader:          w.Client,
+				log:             w.log.WithValues("webhook", "combination"),
+				maxProductSize:  w.maxProductSize,
+				selectableKinds: w.selectableKinds,
+			}).
+			Complete(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
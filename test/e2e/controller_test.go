@@ -6,8 +6,13 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/operator-framework/combo/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 var _ = Describe("Combination controller", func() {
@@ -17,7 +22,7 @@ var _ = Describe("Combination controller", func() {
 		},
 		Spec: v1alpha1.TemplateSpec{
 			Body:       "---\nFIRSTNAME: LASTNAME",
-			Parameters: []string{"FIRSTNAME", "LASTNAME"},
+			Parameters: []v1alpha1.Parameter{{Name: "FIRSTNAME"}, {Name: "LASTNAME"}},
 		},
 	}
 
@@ -27,7 +32,7 @@ var _ = Describe("Combination controller", func() {
 		},
 		Spec: v1alpha1.TemplateSpec{
 			Body:       "---\nFIRSTNAME: foo\nLASTNAME: bar",
-			Parameters: []string{"FIRSTNAME", "LASTNAME"},
+			Parameters: []v1alpha1.Parameter{{Name: "FIRSTNAME"}, {Name: "LASTNAME"}},
 		},
 	}
 
@@ -218,4 +223,994 @@ var _ = Describe("Combination controller", func() {
 			}).Should(ContainElement(v1alpha1.ReasonTemplateNotFound))
 		})
 	})
+	When("given a template with typed parameters", func() {
+		var ctx context.Context
+		var typedTemplateCR *v1alpha1.Template
+		var typedCombinationCR *v1alpha1.Combination
+
+		BeforeEach(func() {
+			ctx = context.Background()
+
+			middleName := "Jon"
+			typedTemplateCR = &v1alpha1.Template{
+				ObjectMeta: metav1.ObjectMeta{
+					GenerateName: "typedtemplate",
+				},
+				Spec: v1alpha1.TemplateSpec{
+					Body: "---\nFIRSTNAME: MIDDLENAME LASTNAME",
+					Parameters: []v1alpha1.Parameter{
+						{Name: "FIRSTNAME", Enum: []string{"John", "Luke"}},
+						{Name: "MIDDLENAME", Default: &middleName},
+						{Name: "LASTNAME", Required: true},
+					},
+				},
+			}
+			err := kubeclient.Create(ctx, typedTemplateCR)
+			Expect(err).To(BeNil(), "failed to create template CR")
+
+			typedCombinationCR = validCombinationCR.DeepCopy()
+			typedCombinationCR.Spec.Template = typedTemplateCR.Name
+		})
+
+		AfterEach(func() {
+			err := kubeclient.Delete(ctx, typedCombinationCR)
+			Expect(err).To(BeNil(), "failed to clean-up combination CR after test")
+
+			err = kubeclient.Delete(ctx, typedTemplateCR)
+			Expect(err).To(BeNil(), "failed to clean-up template CR after test")
+			ctx.Done()
+		})
+
+		It("should fill in the defaults of parameters without arguments", func() {
+			err := kubeclient.Create(ctx, typedCombinationCR)
+			Expect(err).To(BeNil(), "failed to create combination CR")
+
+			Eventually(func(g Gomega) error {
+				var retrievedCombination v1alpha1.Combination
+				if err := kubeclient.Get(ctx, types.NamespacedName{Name: typedCombinationCR.Name}, &retrievedCombination); err != nil {
+					return err
+				}
+
+				g.Expect(retrievedCombination.Status.Evaluations).To(ContainElements("John: Jon Snow", "Luke: Jon Skywalker"))
+				return nil
+			}).Should(Succeed())
+		})
+
+		It("should fail and output an ArgumentsInvalid status when the arguments do not satisfy the parameters", func() {
+			typedCombinationCR.Spec.Arguments = []v1alpha1.Argument{
+				{Key: "FIRSTNAME", Values: []string{"Leia"}},
+				{Key: "LASTNAME", Values: []string{"Organa"}},
+			}
+			err := kubeclient.Create(ctx, typedCombinationCR)
+			Expect(err).To(BeNil(), "failed to create combination CR")
+
+			Eventually(func() ([]string, error) {
+				var retrievedCombination v1alpha1.Combination
+				err := kubeclient.Get(ctx, types.NamespacedName{Name: typedCombinationCR.Name}, &retrievedCombination)
+
+				var conditionReasons []string
+				for _, condition := range retrievedCombination.Status.Conditions {
+					conditionReasons = append(conditionReasons, condition.Reason)
+				}
+
+				return conditionReasons, err
+			}).Should(ContainElement(v1alpha1.ReasonArgumentsInvalid))
+		})
+
+		It("should fail and output an ArgumentsMismatch status when the arguments do not match the parameters", func() {
+			typedCombinationCR.Spec.Arguments = []v1alpha1.Argument{
+				{Key: "FIRSTNAME", Values: []string{"Luke"}},
+				{Key: "SURNAME", Values: []string{"Skywalker"}},
+			}
+			err := kubeclient.Create(ctx, typedCombinationCR)
+			Expect(err).To(BeNil(), "failed to create combination CR")
+
+			Eventually(func(g Gomega) error {
+				var retrievedCombination v1alpha1.Combination
+				if err := kubeclient.Get(ctx, types.NamespacedName{Name: typedCombinationCR.Name}, &retrievedCombination); err != nil {
+					return err
+				}
+
+				condition := meta.FindStatusCondition(retrievedCombination.Status.Conditions, v1alpha1.TypeInvalid)
+				g.Expect(condition).NotTo(BeNil())
+				g.Expect(condition.Reason).To(Equal(v1alpha1.ReasonArgumentsMismatch))
+				g.Expect(condition.Message).To(ContainSubstring("unknown keys: SURNAME; missing keys: LASTNAME"))
+				return nil
+			}).Should(Succeed())
+		})
+	})
+
+	When("given a template of kubernetes resources", func() {
+		var ctx context.Context
+		var resourceTemplateCR *v1alpha1.Template
+		var resourceCombinationCR *v1alpha1.Combination
+
+		BeforeEach(func() {
+			ctx = context.Background()
+
+			resourceTemplateCR = &v1alpha1.Template{
+				ObjectMeta: metav1.ObjectMeta{
+					GenerateName: "resourcetemplate",
+				},
+				Spec: v1alpha1.TemplateSpec{
+					Body:       "---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: combo-NAME\n  namespace: default\ndata:\n  name: NAME",
+					Parameters: []v1alpha1.Parameter{{Name: "NAME"}},
+				},
+			}
+			err := kubeclient.Create(ctx, resourceTemplateCR)
+			Expect(err).To(BeNil(), "failed to create template CR")
+
+			resourceCombinationCR = &v1alpha1.Combination{
+				ObjectMeta: metav1.ObjectMeta{
+					GenerateName: "resourcecombination",
+				},
+				Spec: v1alpha1.CombinationSpec{
+					Template: resourceTemplateCR.Name,
+					Arguments: []v1alpha1.Argument{
+						{
+							Key:    "NAME",
+							Values: []string{"first", "second"},
+						},
+					},
+				},
+			}
+			err = kubeclient.Create(ctx, resourceCombinationCR)
+			Expect(err).To(BeNil(), "failed to create combination CR")
+		})
+
+		AfterEach(func() {
+			err := client.IgnoreNotFound(kubeclient.Delete(ctx, resourceCombinationCR))
+			Expect(err).To(BeNil(), "failed to clean-up combination CR after test")
+
+			err = kubeclient.Delete(ctx, resourceTemplateCR)
+			Expect(err).To(BeNil(), "failed to clean-up template CR after test")
+
+			for _, name := range []string{"combo-first", "combo-second"} {
+				configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}
+				Expect(client.IgnoreNotFound(kubeclient.Delete(ctx, configMap))).To(Succeed())
+			}
+		})
+
+		It("should apply the evaluations to the cluster", func() {
+			Eventually(func(g Gomega) error {
+				var retrievedCombination v1alpha1.Combination
+				if err := kubeclient.Get(ctx, types.NamespacedName{Name: resourceCombinationCR.Name}, &retrievedCombination); err != nil {
+					return err
+				}
+
+				var conditionReasons []string
+				for _, condition := range retrievedCombination.Status.Conditions {
+					conditionReasons = append(conditionReasons, condition.Reason)
+				}
+				g.Expect(conditionReasons).To(ContainElement(v1alpha1.ReasonApplied))
+				g.Expect(retrievedCombination.Status.Resources).To(HaveLen(2))
+
+				for _, name := range []string{"first", "second"} {
+					var configMap corev1.ConfigMap
+					if err := kubeclient.Get(ctx, types.NamespacedName{Name: "combo-" + name, Namespace: "default"}, &configMap); err != nil {
+						return err
+					}
+					g.Expect(configMap.Data).To(HaveKeyWithValue("name", name))
+				}
+
+				return nil
+			}).Should(Succeed())
+		})
+
+		It("should record what produced each evaluation and the resource it was applied as", func() {
+			Eventually(func(g Gomega) error {
+				var retrievedCombination v1alpha1.Combination
+				if err := kubeclient.Get(ctx, types.NamespacedName{Name: resourceCombinationCR.Name}, &retrievedCombination); err != nil {
+					return err
+				}
+
+				records := retrievedCombination.Status.EvaluationRecords
+				g.Expect(records).To(HaveLen(2))
+				for i, name := range []string{"first", "second"} {
+					g.Expect(records[i].Arguments).To(Equal(map[string]string{"NAME": name}))
+					g.Expect(records[i].ManifestIndex).To(Equal(0))
+					g.Expect(records[i].Hash).To(HavePrefix("sha256:"))
+					g.Expect(records[i].Resource).To(Equal(&v1alpha1.ResourceReference{
+						APIVersion: "v1",
+						Kind:       "ConfigMap",
+						Namespace:  "default",
+						Name:       "combo-" + name,
+					}))
+				}
+				return nil
+			}).Should(Succeed())
+		})
+
+		It("should report its counts and the generation it observed", func() {
+			Eventually(func(g Gomega) error {
+				var retrievedCombination v1alpha1.Combination
+				if err := kubeclient.Get(ctx, types.NamespacedName{Name: resourceCombinationCR.Name}, &retrievedCombination); err != nil {
+					return err
+				}
+
+				status := retrievedCombination.Status
+				g.Expect(status.ObservedGeneration).To(Equal(retrievedCombination.GetGeneration()))
+				g.Expect(status.TotalCombinations).To(Equal(2))
+				g.Expect(status.RenderedManifests).To(Equal(2))
+				g.Expect(status.AppliedManifests).To(Equal(2))
+				g.Expect(status.FailedManifests).To(Equal(0))
+
+				inProgress := meta.FindStatusCondition(status.Conditions, v1alpha1.TypeInProgress)
+				g.Expect(inProgress).NotTo(BeNil())
+				g.Expect(inProgress.Status).To(Equal(metav1.ConditionFalse))
+				return nil
+			}).Should(Succeed())
+		})
+
+		It("should prune resources that are no longer part of the evaluations", func() {
+			Eventually(func() error {
+				var configMap corev1.ConfigMap
+				return kubeclient.Get(ctx, types.NamespacedName{Name: "combo-second", Namespace: "default"}, &configMap)
+			}).Should(Succeed())
+
+			Eventually(func() error {
+				if err := kubeclient.Get(ctx, types.NamespacedName{Name: resourceCombinationCR.Name}, resourceCombinationCR); err != nil {
+					return err
+				}
+				resourceCombinationCR.Spec.Arguments[0].Values = []string{"first"}
+				return kubeclient.Update(ctx, resourceCombinationCR)
+			}).Should(Succeed())
+
+			Eventually(func() bool {
+				var configMap corev1.ConfigMap
+				err := kubeclient.Get(ctx, types.NamespacedName{Name: "combo-second", Namespace: "default"}, &configMap)
+				return apierrors.IsNotFound(err)
+			}).Should(BeTrue(), "failed to prune the combo-second configmap")
+
+			var configMap corev1.ConfigMap
+			err := kubeclient.Get(ctx, types.NamespacedName{Name: "combo-first", Namespace: "default"}, &configMap)
+			Expect(err).To(BeNil(), "combo-first configmap should not have been pruned")
+			Expect(configMap.Labels).To(HaveKeyWithValue(v1alpha1.CombinationLabel, resourceCombinationCR.Name))
+		})
+
+		It("should plan the changes once when annotated for a plan and keep the plan once they're applied", func() {
+			Eventually(func(g Gomega) error {
+				var retrievedCombination v1alpha1.Combination
+				if err := kubeclient.Get(ctx, types.NamespacedName{Name: resourceCombinationCR.Name}, &retrievedCombination); err != nil {
+					return err
+				}
+				g.Expect(retrievedCombination.Status.Resources).To(HaveLen(2))
+				return nil
+			}).Should(Succeed())
+
+			Eventually(func() error {
+				if err := kubeclient.Get(ctx, types.NamespacedName{Name: resourceCombinationCR.Name}, resourceCombinationCR); err != nil {
+					return err
+				}
+				resourceCombinationCR.SetAnnotations(map[string]string{v1alpha1.PlanAnnotation: "true"})
+				resourceCombinationCR.Spec.Arguments[0].Values = []string{"first", "third"}
+				return kubeclient.Update(ctx, resourceCombinationCR)
+			}).Should(Succeed())
+
+			// Once the annotation is removed, the combination is applied according to its mode
+			Eventually(func(g Gomega) error {
+				var retrievedCombination v1alpha1.Combination
+				if err := kubeclient.Get(ctx, types.NamespacedName{Name: resourceCombinationCR.Name}, &retrievedCombination); err != nil {
+					return err
+				}
+				g.Expect(retrievedCombination.Annotations).NotTo(HaveKey(v1alpha1.PlanAnnotation))
+				g.Expect(retrievedCombination.Status.ObservedGeneration).To(Equal(retrievedCombination.Generation))
+
+				var configMap corev1.ConfigMap
+				g.Expect(kubeclient.Get(ctx, types.NamespacedName{Name: "combo-third", Namespace: "default"}, &configMap)).To(Succeed())
+				err := kubeclient.Get(ctx, types.NamespacedName{Name: "combo-second", Namespace: "default"}, &configMap)
+				g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+				return nil
+			}).Should(Succeed())
+
+			// The plan it was applied after stays until the spec changes again
+			Consistently(func(g Gomega) error {
+				var retrievedCombination v1alpha1.Combination
+				if err := kubeclient.Get(ctx, types.NamespacedName{Name: resourceCombinationCR.Name}, &retrievedCombination); err != nil {
+					return err
+				}
+
+				plan := retrievedCombination.Status.Plan
+				g.Expect(plan).NotTo(BeNil())
+				g.Expect(plan.ObservedGeneration).To(Equal(retrievedCombination.Generation))
+				g.Expect(plan.Creates).To(Equal(1))
+				g.Expect(plan.Deletes).To(Equal(1))
+				g.Expect(plan.Unchanged).To(Equal(1))
+				g.Expect(plan.Changes).To(ContainElement(v1alpha1.PlannedChange{
+					Action:   v1alpha1.ActionCreate,
+					Resource: v1alpha1.ResourceReference{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "combo-third"},
+				}))
+				return nil
+			}, "5s").Should(Succeed())
+		})
+
+		It("should leave its resources as they are while suspended", func() {
+			Eventually(func(g Gomega) error {
+				var retrievedCombination v1alpha1.Combination
+				if err := kubeclient.Get(ctx, types.NamespacedName{Name: resourceCombinationCR.Name}, &retrievedCombination); err != nil {
+					return err
+				}
+				g.Expect(retrievedCombination.Status.Resources).To(HaveLen(2))
+				return nil
+			}).Should(Succeed())
+
+			Eventually(func() error {
+				if err := kubeclient.Get(ctx, types.NamespacedName{Name: resourceCombinationCR.Name}, resourceCombinationCR); err != nil {
+					return err
+				}
+				resourceCombinationCR.Spec.Suspend = true
+				resourceCombinationCR.Spec.Arguments[0].Values = []string{"first"}
+				return kubeclient.Update(ctx, resourceCombinationCR)
+			}).Should(Succeed())
+
+			Eventually(func(g Gomega) error {
+				var retrievedCombination v1alpha1.Combination
+				if err := kubeclient.Get(ctx, types.NamespacedName{Name: resourceCombinationCR.Name}, &retrievedCombination); err != nil {
+					return err
+				}
+				suspended := meta.FindStatusCondition(retrievedCombination.Status.Conditions, v1alpha1.TypeSuspended)
+				g.Expect(suspended).NotTo(BeNil())
+				g.Expect(suspended.Status).To(Equal(metav1.ConditionTrue))
+				return nil
+			}).Should(Succeed())
+
+			Consistently(func() error {
+				var configMap corev1.ConfigMap
+				return kubeclient.Get(ctx, types.NamespacedName{Name: "combo-second", Namespace: "default"}, &configMap)
+			}, "5s").Should(Succeed(), "combo-second configmap should not have been pruned while suspended")
+
+			Eventually(func() error {
+				if err := kubeclient.Get(ctx, types.NamespacedName{Name: resourceCombinationCR.Name}, resourceCombinationCR); err != nil {
+					return err
+				}
+				resourceCombinationCR.Spec.Suspend = false
+				return kubeclient.Update(ctx, resourceCombinationCR)
+			}).Should(Succeed())
+
+			Eventually(func() bool {
+				var configMap corev1.ConfigMap
+				err := kubeclient.Get(ctx, types.NamespacedName{Name: "combo-second", Namespace: "default"}, &configMap)
+				return apierrors.IsNotFound(err)
+			}).Should(BeTrue(), "failed to prune the combo-second configmap once resumed")
+		})
+
+		It("should plan the changes of a suspended combination when annotated for a plan without applying them", func() {
+			Eventually(func(g Gomega) error {
+				var retrievedCombination v1alpha1.Combination
+				if err := kubeclient.Get(ctx, types.NamespacedName{Name: resourceCombinationCR.Name}, &retrievedCombination); err != nil {
+					return err
+				}
+				g.Expect(retrievedCombination.Status.Resources).To(HaveLen(2))
+				return nil
+			}).Should(Succeed())
+
+			Eventually(func() error {
+				if err := kubeclient.Get(ctx, types.NamespacedName{Name: resourceCombinationCR.Name}, resourceCombinationCR); err != nil {
+					return err
+				}
+				resourceCombinationCR.SetAnnotations(map[string]string{v1alpha1.PlanAnnotation: "true"})
+				resourceCombinationCR.Spec.Suspend = true
+				resourceCombinationCR.Spec.Arguments[0].Values = []string{"first", "third"}
+				return kubeclient.Update(ctx, resourceCombinationCR)
+			}).Should(Succeed())
+
+			Eventually(func(g Gomega) error {
+				var retrievedCombination v1alpha1.Combination
+				if err := kubeclient.Get(ctx, types.NamespacedName{Name: resourceCombinationCR.Name}, &retrievedCombination); err != nil {
+					return err
+				}
+
+				g.Expect(retrievedCombination.Annotations).NotTo(HaveKey(v1alpha1.PlanAnnotation))
+				plan := retrievedCombination.Status.Plan
+				g.Expect(plan).NotTo(BeNil())
+				g.Expect(plan.ObservedGeneration).To(Equal(retrievedCombination.Generation))
+				g.Expect(plan.Creates).To(Equal(1))
+				g.Expect(plan.Deletes).To(Equal(1))
+				g.Expect(retrievedCombination.Status.Resources).To(HaveLen(2))
+				return nil
+			}).Should(Succeed())
+
+			Consistently(func() bool {
+				var configMap corev1.ConfigMap
+				err := kubeclient.Get(ctx, types.NamespacedName{Name: "combo-third", Namespace: "default"}, &configMap)
+				return apierrors.IsNotFound(err)
+			}, "5s").Should(BeTrue(), "combo-third configmap should not have been created while suspended")
+
+			var configMap corev1.ConfigMap
+			Expect(kubeclient.Get(ctx, types.NamespacedName{Name: "combo-second", Namespace: "default"}, &configMap)).To(Succeed(), "combo-second configmap should not have been pruned while suspended")
+		})
+
+		It("should delete its resources once it is deleted", func() {
+			Eventually(func(g Gomega) error {
+				var retrievedCombination v1alpha1.Combination
+				if err := kubeclient.Get(ctx, types.NamespacedName{Name: resourceCombinationCR.Name}, &retrievedCombination); err != nil {
+					return err
+				}
+				g.Expect(retrievedCombination.Finalizers).To(ContainElement(v1alpha1.CleanupFinalizer))
+				g.Expect(retrievedCombination.Status.Resources).To(HaveLen(2))
+				return nil
+			}).Should(Succeed())
+
+			Expect(kubeclient.Delete(ctx, resourceCombinationCR)).To(Succeed())
+
+			Eventually(func() bool {
+				var configMapList corev1.ConfigMapList
+				err := kubeclient.List(ctx, &configMapList, client.InNamespace("default"), client.MatchingLabels{v1alpha1.CombinationLabel: resourceCombinationCR.Name})
+				return err == nil && len(configMapList.Items) == 0
+			}).Should(BeTrue(), "failed to delete the combination's configmaps")
+
+			Eventually(func() bool {
+				var retrievedCombination v1alpha1.Combination
+				err := kubeclient.Get(ctx, types.NamespacedName{Name: resourceCombinationCR.Name}, &retrievedCombination)
+				return apierrors.IsNotFound(err)
+			}).Should(BeTrue(), "failed to release the combination")
+		})
+
+		It("should orphan its resources once it is deleted with an Orphan deletion policy", func() {
+			Eventually(func() error {
+				if err := kubeclient.Get(ctx, types.NamespacedName{Name: resourceCombinationCR.Name}, resourceCombinationCR); err != nil {
+					return err
+				}
+				resourceCombinationCR.Spec.DeletionPolicy = v1alpha1.DeletionPolicyOrphan
+				return kubeclient.Update(ctx, resourceCombinationCR)
+			}).Should(Succeed())
+
+			Eventually(func(g Gomega) error {
+				var retrievedCombination v1alpha1.Combination
+				if err := kubeclient.Get(ctx, types.NamespacedName{Name: resourceCombinationCR.Name}, &retrievedCombination); err != nil {
+					return err
+				}
+				g.Expect(retrievedCombination.Status.Resources).To(HaveLen(2))
+				return nil
+			}).Should(Succeed())
+
+			Expect(kubeclient.Delete(ctx, resourceCombinationCR)).To(Succeed())
+
+			Eventually(func() bool {
+				var retrievedCombination v1alpha1.Combination
+				err := kubeclient.Get(ctx, types.NamespacedName{Name: resourceCombinationCR.Name}, &retrievedCombination)
+				return apierrors.IsNotFound(err)
+			}).Should(BeTrue(), "failed to release the combination")
+
+			var configMapList corev1.ConfigMapList
+			err := kubeclient.List(ctx, &configMapList, client.InNamespace("default"), client.MatchingLabels{v1alpha1.CombinationLabel: resourceCombinationCR.Name})
+			Expect(err).To(BeNil())
+			Expect(configMapList.Items).To(HaveLen(2), "the combination's configmaps should have been orphaned")
+		})
+	})
+
+	When("given arguments sourced from a configmap", func() {
+		var ctx context.Context
+		var templateCR *v1alpha1.Template
+		var combinationCR *v1alpha1.Combination
+		var configMap *corev1.ConfigMap
+
+		BeforeEach(func() {
+			ctx = context.Background()
+
+			configMap = &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					GenerateName: "sourcedvalues",
+					Namespace:    "default",
+				},
+				Data: map[string]string{"lastnames": "Snow\nSkywalker"},
+			}
+			err := kubeclient.Create(ctx, configMap)
+			Expect(err).To(BeNil(), "failed to create configmap")
+
+			templateCR = validTemplateCR.DeepCopy()
+			err = kubeclient.Create(ctx, templateCR)
+			Expect(err).To(BeNil(), "failed to create template CR")
+
+			combinationCR = validCombinationCR.DeepCopy()
+			combinationCR.Spec.Template = templateCR.Name
+			combinationCR.Spec.Arguments[1] = v1alpha1.Argument{
+				Key: "LASTNAME",
+				ValuesFrom: &v1alpha1.ValuesSource{
+					ConfigMapKeyRef: &v1alpha1.KeyReference{Namespace: configMap.Namespace, Name: configMap.Name, Key: "lastnames"},
+				},
+			}
+			err = kubeclient.Create(ctx, combinationCR)
+			Expect(err).To(BeNil(), "failed to create combination CR")
+		})
+
+		AfterEach(func() {
+			err := kubeclient.Delete(ctx, combinationCR)
+			Expect(err).To(BeNil(), "failed to clean-up combination CR after test")
+
+			err = kubeclient.Delete(ctx, templateCR)
+			Expect(err).To(BeNil(), "failed to clean-up template CR after test")
+
+			err = kubeclient.Delete(ctx, configMap)
+			Expect(err).To(BeNil(), "failed to clean-up configmap after test")
+			ctx.Done()
+		})
+
+		It("should evaluate the values of the configmap", func() {
+			Eventually(func() ([]string, error) {
+				var retrievedCombination v1alpha1.Combination
+				err := kubeclient.Get(ctx, types.NamespacedName{Name: combinationCR.Name}, &retrievedCombination)
+				return retrievedCombination.Status.Evaluations, err
+			}).Should(ConsistOf(expectedEvaluations))
+		})
+
+		It("should reevaluate whenever the configmap gets updated", func() {
+			Eventually(func() ([]string, error) {
+				var retrievedCombination v1alpha1.Combination
+				err := kubeclient.Get(ctx, types.NamespacedName{Name: combinationCR.Name}, &retrievedCombination)
+				return retrievedCombination.Status.Evaluations, err
+			}).Should(ConsistOf(expectedEvaluations))
+
+			configMap.Data["lastnames"] = `["Stark"]`
+			err := kubeclient.Update(ctx, configMap)
+			Expect(err).To(BeNil(), "failed to update configmap")
+
+			Eventually(func() ([]string, error) {
+				var retrievedCombination v1alpha1.Combination
+				err := kubeclient.Get(ctx, types.NamespacedName{Name: combinationCR.Name}, &retrievedCombination)
+				return retrievedCombination.Status.Evaluations, err
+			}).Should(ConsistOf("John: Stark", "Luke: Stark"))
+		})
+
+		It("should fail and output an ArgumentsUnresolved status when the key does not exist", func() {
+			var retrievedCombination v1alpha1.Combination
+			err := kubeclient.Get(ctx, types.NamespacedName{Name: combinationCR.Name}, &retrievedCombination)
+			Expect(err).To(BeNil())
+
+			retrievedCombination.Spec.Arguments[1].ValuesFrom.ConfigMapKeyRef.Key = "missing"
+			err = kubeclient.Update(ctx, &retrievedCombination)
+			Expect(err).To(BeNil(), "failed to update combination CR")
+
+			Eventually(func(g Gomega) error {
+				var retrievedCombination v1alpha1.Combination
+				if err := kubeclient.Get(ctx, types.NamespacedName{Name: combinationCR.Name}, &retrievedCombination); err != nil {
+					return err
+				}
+
+				condition := meta.FindStatusCondition(retrievedCombination.Status.Conditions, v1alpha1.TypeInvalid)
+				g.Expect(condition).NotTo(BeNil())
+				g.Expect(condition.Reason).To(Equal(v1alpha1.ReasonArgumentsUnresolved))
+				g.Expect(condition.Message).To(ContainSubstring("has no missing key"))
+				return nil
+			}).Should(Succeed())
+		})
+	})
+
+	When("given arguments sourced from a secret", func() {
+		var ctx context.Context
+		var templateCR *v1alpha1.Template
+		var combinationCR *v1alpha1.Combination
+		var secret *corev1.Secret
+
+		BeforeEach(func() {
+			ctx = context.Background()
+
+			secret = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					GenerateName: "sourcedvalues",
+					Namespace:    "default",
+				},
+				StringData: map[string]string{"names": "hidden"},
+			}
+			err := kubeclient.Create(ctx, secret)
+			Expect(err).To(BeNil(), "failed to create secret")
+
+			templateCR = &v1alpha1.Template{
+				ObjectMeta: metav1.ObjectMeta{
+					GenerateName: "secrettemplate",
+				},
+				Spec: v1alpha1.TemplateSpec{
+					Body:       "---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: combo-NAME\n  namespace: default",
+					Parameters: []v1alpha1.Parameter{{Name: "NAME"}},
+				},
+			}
+			err = kubeclient.Create(ctx, templateCR)
+			Expect(err).To(BeNil(), "failed to create template CR")
+
+			combinationCR = &v1alpha1.Combination{
+				ObjectMeta: metav1.ObjectMeta{
+					GenerateName: "secretcombination",
+				},
+				Spec: v1alpha1.CombinationSpec{
+					Template: templateCR.Name,
+					Arguments: []v1alpha1.Argument{
+						{
+							Key: "NAME",
+							ValuesFrom: &v1alpha1.ValuesSource{
+								SecretKeyRef: &v1alpha1.KeyReference{Namespace: secret.Namespace, Name: secret.Name, Key: "names"},
+							},
+						},
+					},
+				},
+			}
+			err = kubeclient.Create(ctx, combinationCR)
+			Expect(err).To(BeNil(), "failed to create combination CR")
+		})
+
+		AfterEach(func() {
+			err := kubeclient.Delete(ctx, combinationCR)
+			Expect(err).To(BeNil(), "failed to clean-up combination CR after test")
+
+			err = kubeclient.Delete(ctx, templateCR)
+			Expect(err).To(BeNil(), "failed to clean-up template CR after test")
+
+			err = kubeclient.Delete(ctx, secret)
+			Expect(err).To(BeNil(), "failed to clean-up secret after test")
+
+			configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "combo-hidden", Namespace: "default"}}
+			Expect(client.IgnoreNotFound(kubeclient.Delete(ctx, configMap))).To(Succeed())
+		})
+
+		It("should redact the values of the secret from its records but keep the names of its resources", func() {
+			Eventually(func(g Gomega) error {
+				var retrievedCombination v1alpha1.Combination
+				if err := kubeclient.Get(ctx, types.NamespacedName{Name: combinationCR.Name}, &retrievedCombination); err != nil {
+					return err
+				}
+
+				status := retrievedCombination.Status
+				g.Expect(status.Evaluations).To(BeEmpty())
+				g.Expect(status.EvaluationRecords).To(HaveLen(1))
+				g.Expect(status.EvaluationRecords[0].Arguments).To(Equal(map[string]string{"NAME": "<redacted>"}))
+				g.Expect(status.EvaluationRecords[0].Resource).NotTo(BeNil())
+				g.Expect(status.EvaluationRecords[0].Resource.Name).To(Equal("combo-<redacted>"))
+
+				// The inventory keeps the names rendered from the secret, since pruning needs them
+				g.Expect(status.Resources).To(HaveLen(1))
+				g.Expect(status.Resources[0].Name).To(Equal("combo-hidden"))
+				return nil
+			}).Should(Succeed())
+		})
+	})
+
+	When("given arguments selecting namespaces by their labels", func() {
+		var ctx context.Context
+		var templateCR *v1alpha1.Template
+		var combinationCR *v1alpha1.Combination
+		var namespaces []*corev1.Namespace
+		var tenant string
+
+		BeforeEach(func() {
+			ctx = context.Background()
+			namespaces = nil
+
+			first := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{GenerateName: "tenant-"}}
+			err := kubeclient.Create(ctx, first)
+			Expect(err).To(BeNil(), "failed to create namespace")
+			namespaces = append(namespaces, first)
+
+			// Label the namespaces with a value unique to the test so other namespaces are never selected
+			tenant = first.Name
+			first.Labels = map[string]string{"combo.io/tenant": tenant}
+			err = kubeclient.Update(ctx, first)
+			Expect(err).To(BeNil(), "failed to label namespace")
+
+			templateCR = &v1alpha1.Template{
+				ObjectMeta: metav1.ObjectMeta{
+					GenerateName: "tenanttemplate",
+				},
+				Spec: v1alpha1.TemplateSpec{
+					Body:       "---\nnamespace: NAMESPACE",
+					Parameters: []v1alpha1.Parameter{{Name: "NAMESPACE"}},
+				},
+			}
+			err = kubeclient.Create(ctx, templateCR)
+			Expect(err).To(BeNil(), "failed to create template CR")
+
+			combinationCR = &v1alpha1.Combination{
+				ObjectMeta: metav1.ObjectMeta{
+					GenerateName: "tenantcombination",
+				},
+				Spec: v1alpha1.CombinationSpec{
+					Template: templateCR.Name,
+					Arguments: []v1alpha1.Argument{
+						{
+							Key: "NAMESPACE",
+							ValuesFrom: &v1alpha1.ValuesSource{
+								NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"combo.io/tenant": tenant}},
+							},
+						},
+					},
+				},
+			}
+			err = kubeclient.Create(ctx, combinationCR)
+			Expect(err).To(BeNil(), "failed to create combination CR")
+		})
+
+		AfterEach(func() {
+			err := kubeclient.Delete(ctx, combinationCR)
+			Expect(err).To(BeNil(), "failed to clean-up combination CR after test")
+
+			err = kubeclient.Delete(ctx, templateCR)
+			Expect(err).To(BeNil(), "failed to clean-up template CR after test")
+
+			for _, namespace := range namespaces {
+				err = kubeclient.Delete(ctx, namespace)
+				Expect(err).To(BeNil(), "failed to clean-up namespace after test")
+			}
+			ctx.Done()
+		})
+
+		It("should evaluate new namespaces as they are labeled", func() {
+			Eventually(func() ([]string, error) {
+				var retrievedCombination v1alpha1.Combination
+				err := kubeclient.Get(ctx, types.NamespacedName{Name: combinationCR.Name}, &retrievedCombination)
+				return retrievedCombination.Status.Evaluations, err
+			}).Should(ConsistOf("namespace: " + namespaces[0].Name))
+
+			second := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "tenant-",
+				Labels:       map[string]string{"combo.io/tenant": tenant},
+			}}
+			err := kubeclient.Create(ctx, second)
+			Expect(err).To(BeNil(), "failed to create namespace")
+			namespaces = append(namespaces, second)
+
+			Eventually(func() ([]string, error) {
+				var retrievedCombination v1alpha1.Combination
+				err := kubeclient.Get(ctx, types.NamespacedName{Name: combinationCR.Name}, &retrievedCombination)
+				return retrievedCombination.Status.Evaluations, err
+			}).Should(ConsistOf("namespace: "+namespaces[0].Name, "namespace: "+second.Name))
+		})
+
+		It("should stop evaluating namespaces once they are no longer selected", func() {
+			Eventually(func() ([]string, error) {
+				var retrievedCombination v1alpha1.Combination
+				err := kubeclient.Get(ctx, types.NamespacedName{Name: combinationCR.Name}, &retrievedCombination)
+				return retrievedCombination.Status.Evaluations, err
+			}).Should(ConsistOf("namespace: " + namespaces[0].Name))
+
+			namespaces[0].Labels = nil
+			err := kubeclient.Update(ctx, namespaces[0])
+			Expect(err).To(BeNil(), "failed to unlabel namespace")
+
+			Eventually(func() ([]string, error) {
+				var retrievedCombination v1alpha1.Combination
+				err := kubeclient.Get(ctx, types.NamespacedName{Name: combinationCR.Name}, &retrievedCombination)
+				return retrievedCombination.Status.Evaluations, err
+			}).Should(BeEmpty())
+		})
+	})
+})
+
+var _ = Describe("Template controller", func() {
+	var ctx context.Context
+	var templateCR *v1alpha1.Template
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		templateCR = &v1alpha1.Template{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "statustemplate",
+			},
+			Spec: v1alpha1.TemplateSpec{
+				Body:       "---\nname: ${{ FIRSTNAME }}\n---\nname: ${{ NICKNAME }}",
+				Parameters: []v1alpha1.Parameter{{Name: "FIRSTNAME"}, {Name: "LASTNAME"}},
+				Delimiters: &v1alpha1.Delimiters{Left: "${{", Right: "}}"},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		err := kubeclient.Delete(ctx, templateCR)
+		Expect(err).To(BeNil(), "failed to clean-up template CR after test")
+		ctx.Done()
+	})
+
+	It("should report the parameters it finds and the combinations that evaluate it", func() {
+		err := kubeclient.Create(ctx, templateCR)
+		Expect(err).To(BeNil(), "failed to create template CR")
+
+		combinationCR := &v1alpha1.Combination{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "statuscombination",
+			},
+			Spec: v1alpha1.CombinationSpec{
+				Template:  templateCR.Name,
+				Arguments: []v1alpha1.Argument{{Key: "FIRSTNAME", Values: []string{"John"}}},
+			},
+		}
+		err = kubeclient.Create(ctx, combinationCR)
+		Expect(err).To(BeNil(), "failed to create combination CR")
+		defer func() {
+			err := kubeclient.Delete(ctx, combinationCR)
+			Expect(err).To(BeNil(), "failed to clean-up combination CR after test")
+		}()
+
+		Eventually(func(g Gomega) error {
+			var retrievedTemplate v1alpha1.Template
+			if err := kubeclient.Get(ctx, types.NamespacedName{Name: templateCR.Name}, &retrievedTemplate); err != nil {
+				return err
+			}
+
+			condition := meta.FindStatusCondition(retrievedTemplate.Status.Conditions, v1alpha1.TypeValid)
+			g.Expect(condition).NotTo(BeNil())
+			g.Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+			g.Expect(condition.Message).To(ContainSubstring("declared parameters not found in the body: LASTNAME"))
+			g.Expect(condition.Message).To(ContainSubstring("parameters found in the body but not declared: NICKNAME"))
+			g.Expect(retrievedTemplate.Status.Manifests).To(Equal(2))
+			g.Expect(retrievedTemplate.Status.DeclaredParameters).To(Equal([]string{"FIRSTNAME", "LASTNAME"}))
+			g.Expect(retrievedTemplate.Status.DiscoveredParameters).To(Equal([]string{"FIRSTNAME", "NICKNAME"}))
+			g.Expect(retrievedTemplate.Status.Combinations).To(Equal([]string{combinationCR.Name}))
+			return nil
+		}).Should(Succeed())
+	})
+
+	It("should not be valid when its body is not valid YAML", func() {
+		templateCR.Spec.Body = "---\nname: [${{ FIRSTNAME }}"
+		err := kubeclient.Create(ctx, templateCR)
+		Expect(err).To(BeNil(), "failed to create template CR")
+
+		Eventually(func(g Gomega) error {
+			var retrievedTemplate v1alpha1.Template
+			if err := kubeclient.Get(ctx, types.NamespacedName{Name: templateCR.Name}, &retrievedTemplate); err != nil {
+				return err
+			}
+
+			condition := meta.FindStatusCondition(retrievedTemplate.Status.Conditions, v1alpha1.TypeValid)
+			g.Expect(condition).NotTo(BeNil())
+			g.Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			g.Expect(condition.Reason).To(Equal(v1alpha1.ReasonTemplateBodyInvalid))
+			return nil
+		}).Should(Succeed())
+	})
+})
+
+var _ = Describe("Namespaced combination controller", func() {
+	var ctx context.Context
+	var namespace *corev1.Namespace
+	var roleBinding *rbacv1.RoleBinding
+	var templateCR *v1alpha1.NamespacedTemplate
+	var combinationCR *v1alpha1.NamespacedCombination
+
+	BeforeEach(func() {
+		ctx = context.Background()
+
+		namespace = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{GenerateName: "tenant"}}
+		err := kubeclient.Create(ctx, namespace)
+		Expect(err).To(BeNil(), "failed to create namespace")
+
+		// Resources are managed as the default ServiceAccount of the namespace, which has no permissions of its own
+		roleBinding = &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "combo-default",
+				Namespace: namespace.Name,
+			},
+			RoleRef:  rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "edit"},
+			Subjects: []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Namespace: namespace.Name, Name: "default"}},
+		}
+		err = kubeclient.Create(ctx, roleBinding)
+		Expect(err).To(BeNil(), "failed to create role binding")
+
+		templateCR = &v1alpha1.NamespacedTemplate{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "namespacedtemplate",
+				Namespace:    namespace.Name,
+			},
+			Spec: v1alpha1.TemplateSpec{
+				Body:       "---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: combo-NAME\ndata:\n  name: NAME",
+				Parameters: []v1alpha1.Parameter{{Name: "NAME"}},
+			},
+		}
+		err = kubeclient.Create(ctx, templateCR)
+		Expect(err).To(BeNil(), "failed to create namespaced template CR")
+
+		combinationCR = &v1alpha1.NamespacedCombination{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "namespacedcombination",
+				Namespace:    namespace.Name,
+			},
+			Spec: v1alpha1.CombinationSpec{
+				Template:  templateCR.Name,
+				Arguments: []v1alpha1.Argument{{Key: "NAME", Values: []string{"first", "second"}}},
+			},
+		}
+		err = kubeclient.Create(ctx, combinationCR)
+		Expect(err).To(BeNil(), "failed to create namespaced combination CR")
+	})
+
+	AfterEach(func() {
+		err := client.IgnoreNotFound(kubeclient.Delete(ctx, combinationCR))
+		Expect(err).To(BeNil(), "failed to clean-up namespaced combination CR after test")
+
+		err = kubeclient.Delete(ctx, templateCR)
+		Expect(err).To(BeNil(), "failed to clean-up namespaced template CR after test")
+
+		err = kubeclient.Delete(ctx, namespace)
+		Expect(err).To(BeNil(), "failed to clean-up namespace after test")
+	})
+
+	It("should apply the evaluations to its namespace", func() {
+		Eventually(func(g Gomega) error {
+			var retrievedCombination v1alpha1.NamespacedCombination
+			if err := kubeclient.Get(ctx, client.ObjectKeyFromObject(combinationCR), &retrievedCombination); err != nil {
+				return err
+			}
+
+			condition := meta.FindStatusCondition(retrievedCombination.Status.Conditions, v1alpha1.TypeApplied)
+			g.Expect(condition).NotTo(BeNil())
+			g.Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+
+			for _, name := range []string{"first", "second"} {
+				var configMap corev1.ConfigMap
+				if err := kubeclient.Get(ctx, types.NamespacedName{Name: "combo-" + name, Namespace: namespace.Name}, &configMap); err != nil {
+					return err
+				}
+				g.Expect(configMap.Labels).To(HaveKeyWithValue(v1alpha1.NamespacedCombinationLabel, combinationCR.Name))
+			}
+			return nil
+		}).Should(Succeed())
+	})
+
+	It("should fail to apply resources outside of its namespace", func() {
+		var retrievedTemplate v1alpha1.NamespacedTemplate
+		err := kubeclient.Get(ctx, client.ObjectKeyFromObject(templateCR), &retrievedTemplate)
+		Expect(err).To(BeNil())
+
+		retrievedTemplate.Spec.Body = "---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: combo-NAME\n  namespace: default\n" +
+			"---\napiVersion: v1\nkind: Namespace\nmetadata:\n  name: combo-NAME"
+		err = kubeclient.Update(ctx, &retrievedTemplate)
+		Expect(err).To(BeNil(), "failed to update namespaced template CR")
+
+		Eventually(func(g Gomega) error {
+			var retrievedCombination v1alpha1.NamespacedCombination
+			if err := kubeclient.Get(ctx, client.ObjectKeyFromObject(combinationCR), &retrievedCombination); err != nil {
+				return err
+			}
+
+			g.Expect(retrievedCombination.Status.Resources).To(HaveLen(4))
+			for _, resource := range retrievedCombination.Status.Resources {
+				g.Expect(resource.Result).To(Equal(v1alpha1.ResultFailed))
+				g.Expect(resource.Message).To(ContainSubstring("outside of the combination's namespace"))
+			}
+			return nil
+		}).Should(Succeed())
+
+		err = kubeclient.Get(ctx, types.NamespacedName{Name: "combo-first"}, &corev1.Namespace{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue(), "the namespace should not have been created")
+	})
+
+	It("should orphan its resources once it is deleted without access to them", func() {
+		Eventually(func() error {
+			var configMap corev1.ConfigMap
+			return kubeclient.Get(ctx, types.NamespacedName{Name: "combo-first", Namespace: namespace.Name}, &configMap)
+		}).Should(Succeed())
+
+		err := kubeclient.Delete(ctx, roleBinding)
+		Expect(err).To(BeNil(), "failed to delete role binding")
+
+		err = kubeclient.Delete(ctx, combinationCR)
+		Expect(err).To(BeNil(), "failed to delete namespaced combination CR")
+
+		Eventually(func() bool {
+			err := kubeclient.Get(ctx, client.ObjectKeyFromObject(combinationCR), &v1alpha1.NamespacedCombination{})
+			return apierrors.IsNotFound(err)
+		}).Should(BeTrue(), "the namespaced combination should have been deleted")
+
+		for _, name := range []string{"combo-first", "combo-second"} {
+			var configMap corev1.ConfigMap
+			err := kubeclient.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace.Name}, &configMap)
+			Expect(err).To(BeNil(), "%s configmap should have been orphaned", name)
+		}
+
+		Eventually(func() ([]string, error) {
+			var events corev1.EventList
+			err := kubeclient.List(ctx, &events, client.InNamespace(namespace.Name))
+			var reasons []string
+			for _, event := range events.Items {
+				if event.InvolvedObject.Name == combinationCR.Name {
+					reasons = append(reasons, event.Reason)
+				}
+			}
+			return reasons, err
+		}).Should(ContainElement("PruneForbidden"))
+	})
+
+	It("should report the combinations that evaluate its template", func() {
+		Eventually(func() ([]string, error) {
+			var retrievedTemplate v1alpha1.NamespacedTemplate
+			err := kubeclient.Get(ctx, client.ObjectKeyFromObject(templateCR), &retrievedTemplate)
+			return retrievedTemplate.Status.Combinations, err
+		}).Should(Equal([]string{combinationCR.Name}))
+	})
 })